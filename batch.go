@@ -0,0 +1,234 @@
+package fastdb
+
+import (
+	"fastdb/index"
+	"fastdb/storage"
+	"time"
+)
+
+// WriteBatch collects the writes of different keys and commits them atomically.
+// The entries of a batch are followed by a commit entry in the db file of every data type in the batch,
+// the entries without commit will be ignored when loading the db files, so all or none of the writes take effect.
+// A WriteBatch is not safe for concurrent use.
+//
+//	wb := db.NewWriteBatch()
+//	_ = wb.Set([]byte("k1"), []byte("v1"))
+//	_ = wb.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+//	err := wb.Commit()
+type WriteBatch struct {
+	db     *FastDB
+	ops    []batchOp
+	closed bool
+}
+
+// a write operation in the batch.
+type batchOp struct {
+	dType DataType
+	mark  uint16
+	key   []byte
+	value []byte
+	extra []byte
+}
+
+// NewWriteBatch create a new write batch.
+func (db *FastDB) NewWriteBatch() *WriteBatch {
+	return &WriteBatch{db: db}
+}
+
+// Set set key to hold the string value when the batch is committed.
+func (wb *WriteBatch) Set(key, value []byte) error {
+	if err := wb.db.checkKeyValue(key, value); err != nil {
+		return err
+	}
+	return wb.add(batchOp{dType: String, mark: StringSet, key: key, value: value})
+}
+
+// StrRem remove the string value stored at key when the batch is committed.
+func (wb *WriteBatch) StrRem(key []byte) error {
+	if err := wb.db.checkKeyValue(key, nil); err != nil {
+		return err
+	}
+	return wb.add(batchOp{dType: String, mark: StringRem, key: key})
+}
+
+// HSet set field in the hash stored at key to value when the batch is committed.
+func (wb *WriteBatch) HSet(key, field, value []byte) error {
+	if err := wb.db.checkKeyValue(key, value, field); err != nil {
+		return err
+	}
+	return wb.add(batchOp{dType: Hash, mark: HashHSet, key: key, value: value, extra: field})
+}
+
+// HDel remove the specified fields from the hash stored at key when the batch is committed.
+func (wb *WriteBatch) HDel(key []byte, fields ...[]byte) error {
+	if err := wb.db.checkKeyValue(key, nil); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := wb.add(batchOp{dType: Hash, mark: HashHDel, key: key, extra: f}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard drop all the writes in the batch, the batch can`t be used any more.
+func (wb *WriteBatch) Discard() {
+	wb.ops = nil
+	wb.closed = true
+}
+
+// Commit write all the entries of the batch, then the commit entries, and apply the writes to the indexes.
+// The batch can`t be used any more after committed, even if an error is returned.
+func (wb *WriteBatch) Commit() error {
+	if wb.closed {
+		return ErrBatchClosed
+	}
+	wb.closed = true
+	return wb.db.commitBatch(wb.ops, nil, nil)
+}
+
+// commit the operations atomically.
+// The data types of the operations and readTypes are locked, then check is called before writing anything.
+func (db *FastDB) commitBatch(ops []batchOp, readTypes []DataType, check func() error) (err error) {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	var dTypes, lockTypes []DataType
+	for dType := String; dType < DataStructureNum; dType++ {
+		var written, read bool
+		for _, op := range ops {
+			if op.dType == dType {
+				written = true
+				break
+			}
+		}
+		for _, t := range readTypes {
+			if t == dType {
+				read = true
+				break
+			}
+		}
+		if written {
+			dTypes = append(dTypes, dType)
+		}
+		if written || read {
+			lockTypes = append(lockTypes, dType)
+		}
+	}
+
+	// lock the data types in a fixed order, so no other writes of them can be mixed into the batch.
+	for _, dType := range lockTypes {
+		db.getIdxLock(dType).Lock()
+		defer db.getIdxLock(dType).Unlock()
+	}
+
+	if check != nil {
+		if err = check(); err != nil {
+			return
+		}
+	}
+
+	// clear the expired keys first, the writes of the batch are applied to new keys.
+	for _, op := range ops {
+		if op.dType != String {
+			db.checkExpired(op.key, op.dType)
+		}
+	}
+
+	for _, op := range ops {
+		switch op.dType {
+		case String:
+			db.saveStrSnapshots(op.key)
+		case Hash:
+			db.saveHashSnapshots(op.key)
+		}
+	}
+
+	indexes, err := db.writeBatch(ops, dTypes)
+	if indexes == nil {
+		return
+	}
+
+	for i, op := range ops {
+		switch op.dType {
+		case String:
+			db.incrReclaimableSpace(op.key)
+			delete(db.expires[String], string(op.key))
+			if op.mark == StringSet {
+				db.strIndex.put(op.key, indexes[i])
+			} else {
+				db.strIndex.remove(op.key)
+			}
+		case Hash:
+			if op.mark == HashHSet {
+				idxVal := db.hashIdxVal(op.value, indexes[i].FileId, indexes[i].Offset)
+				db.hashIndex.indexes.HSet(string(op.key), string(op.extra), idxVal)
+			} else {
+				db.hashIndex.indexes.HDel(string(op.key), string(op.extra))
+			}
+		}
+	}
+	return
+}
+
+// write the entries and the commit entries of the batch, returns the String and Hash indexers of the entries.
+// The indexers are nil if the batch is not committed, otherwise it should be applied even if an error occurred.
+func (db *FastDB) writeBatch(ops []batchOp, dTypes []DataType) (indexes []*index.Indexer, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	batchId := uint64(time.Now().UnixNano())
+	if batchId <= db.lastBatchId {
+		batchId = db.lastBatchId + 1
+	}
+	db.lastBatchId = batchId
+
+	res := make([]*index.Indexer, len(ops))
+	for i, op := range ops {
+		e := storage.NewBatchEntry(op.key, op.value, op.extra, op.dType, op.mark, batchId)
+		if err = db.writeEntry(e, false); err != nil {
+			return
+		}
+		if op.dType == String || op.dType == Hash {
+			res[i] = &index.Indexer{
+				Meta: &storage.Meta{
+					KeySize:   uint32(len(e.Meta.Key)),
+					Key:       e.Meta.Key,
+					ValueSize: uint32(len(e.Meta.Value)),
+				},
+				FileId:    db.activeFileIds[op.dType],
+				EntrySize: e.Size(),
+				Offset:    db.activeFile[op.dType].Offset - int64(e.Size()),
+			}
+			if db.config.IdxMode == KeyValueMemMode {
+				res[i].Meta.Value = e.Meta.Value
+			}
+		}
+	}
+
+	// the entries must be persisted before any commit entry.
+	for _, dType := range dTypes {
+		if err = db.activeFile[dType].Sync(); err != nil {
+			return
+		}
+	}
+
+	// the batch is committed once a commit entry is written, the missing ones will be added when opening the db.
+	for _, dType := range dTypes {
+		if err = db.writeEntry(storage.NewBatchCommitEntry(dType, batchId), db.config.Sync); err != nil {
+			return
+		}
+		indexes = res
+	}
+	return
+}
+
+func (wb *WriteBatch) add(op batchOp) error {
+	if wb.closed {
+		return ErrBatchClosed
+	}
+	wb.ops = append(wb.ops, op)
+	return nil
+}
@@ -0,0 +1,126 @@
+package fastdb
+
+import (
+	"fastdb/storage"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteBatch_Commit(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := InitTestDb(t, mode)
+
+		_ = db.Set([]byte("removed"), []byte("v"))
+		_, _ = db.HSet([]byte("my_hash"), []byte("f0"), []byte("v0"))
+
+		wb := db.NewWriteBatch()
+		assert.Equal(t, ErrEmptyKey, wb.Set(nil, []byte("v")))
+		assert.Nil(t, wb.Set([]byte("k1"), []byte("v1")))
+		assert.Nil(t, wb.Set([]byte("k1"), []byte("v2")))
+		assert.Nil(t, wb.StrRem([]byte("removed")))
+		assert.Nil(t, wb.HSet([]byte("my_hash"), []byte("f1"), []byte("v1")))
+		assert.Nil(t, wb.HDel([]byte("my_hash"), []byte("f0")))
+
+		// nothing is visible before committing.
+		assert.False(t, db.StrExists([]byte("k1")))
+		assert.Nil(t, wb.Commit())
+		assert.Equal(t, ErrBatchClosed, wb.Commit())
+		assert.Equal(t, ErrBatchClosed, wb.Set([]byte("k2"), []byte("v2")))
+
+		check := func(db *FastDB) {
+			val, err := db.Get([]byte("k1"))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("v2"), val)
+			assert.False(t, db.StrExists([]byte("removed")))
+			assert.Equal(t, []byte("v1"), db.HGet([]byte("my_hash"), []byte("f1")))
+			assert.Nil(t, db.HGet([]byte("my_hash"), []byte("f0")))
+		}
+		db = CheckAndReopen(t, db, check)
+		_ = db.Close()
+	}
+}
+
+func TestWriteBatch_Discard(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	wb := db.NewWriteBatch()
+	_ = wb.Set([]byte("k1"), []byte("v1"))
+	wb.Discard()
+	assert.Equal(t, ErrBatchClosed, wb.Commit())
+	assert.False(t, db.StrExists([]byte("k1")))
+}
+
+func TestWriteBatch_Recover(t *testing.T) {
+	t.Run("not committed", func(t *testing.T) {
+		db := InitTestDb(t, KeyOnlyMemMode)
+		_ = db.Set([]byte("k1"), []byte("old"))
+
+		// the process crashed before writing any commit entry.
+		assert.Nil(t, db.store(storage.NewBatchEntry([]byte("k1"), []byte("new"), nil, String, StringSet, 1)))
+		assert.Nil(t, db.store(storage.NewBatchEntry([]byte("h1"), []byte("v1"), []byte("f1"), Hash, HashHSet, 1)))
+
+		db = CloseAndReopen(t, db)
+		val, _ := db.Get([]byte("k1"))
+		assert.Equal(t, []byte("old"), val)
+		assert.Nil(t, db.HGet([]byte("h1"), []byte("f1")))
+
+		// the new batch id won`t commit the discarded entries.
+		assert.True(t, db.lastBatchId >= 1)
+		wb := db.NewWriteBatch()
+		_ = wb.Set([]byte("k2"), []byte("v2"))
+		assert.Nil(t, wb.Commit())
+
+		db = CloseAndReopen(t, db)
+		defer db.Close()
+		val, _ = db.Get([]byte("k1"))
+		assert.Equal(t, []byte("old"), val)
+		val, _ = db.Get([]byte("k2"))
+		assert.Equal(t, []byte("v2"), val)
+	})
+
+	t.Run("partly committed", func(t *testing.T) {
+		db := InitTestDb(t, KeyValueMemMode)
+
+		// the process crashed after writing the commit entry of String.
+		assert.Nil(t, db.store(storage.NewBatchEntry([]byte("k1"), []byte("v1"), nil, String, StringSet, 1)))
+		assert.Nil(t, db.store(storage.NewBatchEntry([]byte("h1"), []byte("v1"), []byte("f1"), Hash, HashHSet, 1)))
+		assert.Nil(t, db.store(storage.NewBatchCommitEntry(String, 1)))
+
+		db = CloseAndReopen(t, db)
+		assert.Equal(t, []byte("v1"), db.HGet([]byte("h1"), []byte("f1")))
+
+		// the missing commit entry has been written, so the later writes are not overwritten.
+		_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("v2"))
+
+		db = CloseAndReopen(t, db)
+		defer db.Close()
+		val, _ := db.Get([]byte("k1"))
+		assert.Equal(t, []byte("v1"), val)
+		assert.Equal(t, []byte("v2"), db.HGet([]byte("h1"), []byte("f1")))
+	})
+}
+
+func TestWriteBatch_Reclaim(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+
+	for i := 0; i < 200; i++ {
+		wb := db.NewWriteBatch()
+		for j := 0; j < 10; j++ {
+			_ = wb.Set([]byte(fmt.Sprintf("key-%d", j)), []byte(fmt.Sprintf("val-%d", i)))
+		}
+		assert.Nil(t, wb.Commit())
+	}
+	assert.Nil(t, db.Reclaim())
+
+	// the reclaimed entries take effect without the commit entries.
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+	for j := 0; j < 10; j++ {
+		val, err := db.Get([]byte(fmt.Sprintf("key-%d", j)))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("val-199"), val)
+	}
+}
@@ -15,6 +15,7 @@ import (
 var commandList = [][]string{
 	{"SET", "key value", "STRING"},
 	{"GET", "key", "STRING"},
+	{"SETEX", "key seconds value", "STRING"},
 	{"SETNX", "key value", "STRING"},
 	{"GETSET", "key value", "STRING"},
 	{"APPEND", "key value", "STRING"},
@@ -40,6 +41,10 @@ var commandList = [][]string{
 	{"LLEN", "key", "LIST"},
 	{"LKEYEXISTS", "key", "LIST"},
 	{"LVALEXISTS", "key value", "LIST"},
+	{"LCLEAR", "key", "LIST"},
+	{"LEXPIRE", "key seconds", "LIST"},
+	{"LPERSIST", "key", "LIST"},
+	{"LTTL", "key", "LIST"},
 
 	{"HSET", "key field value", "HASH"},
 	{"HSETNX", "key field value", "HASH"},
@@ -50,6 +55,11 @@ var commandList = [][]string{
 	{"HLEN", "key", "HASH"},
 	{"HKEYS", "key", "HASH"},
 	{"HVALS", "key", "HASH"},
+	{"HKEYEXISTS", "key", "HASH"},
+	{"HCLEAR", "key", "HASH"},
+	{"HEXPIRE", "key seconds", "HASH"},
+	{"HPERSIST", "key", "HASH"},
+	{"HTTL", "key", "HASH"},
 
 	{"SADD", "key members [members...]", "SET"},
 	{"SPOP", "key count", "SET"},
@@ -57,10 +67,15 @@ var commandList = [][]string{
 	{"SRANDMEMBER", "key count", "SET"},
 	{"SREM", "key members [members...]", "SET"},
 	{"SMOVE", "src dst member", "SET"},
-	{"SCARD", "key", "key", "SET"},
+	{"SCARD", "key", "SET"},
 	{"SMEMBERS", "key", "SET"},
 	{"SUNION", "key [key...]", "SET"},
 	{"SDIFF", "key [key...]", "SET"},
+	{"SKEYEXISTS", "key", "SET"},
+	{"SCLEAR", "key", "SET"},
+	{"SEXPIRE", "key seconds", "SET"},
+	{"SPERSIST", "key", "SET"},
+	{"STTL", "key", "SET"},
 
 	{"ZADD", "key score member", "ZSET"},
 	{"ZSCORE", "key member", "ZSET"},
@@ -68,13 +83,30 @@ var commandList = [][]string{
 	{"ZRANK", "key member", "ZSET"},
 	{"ZREVRANK", "key member", "ZSET"},
 	{"ZINCRBY", "key increment member", "ZSET"},
-	{"ZRANGE", "key start stop", "ZSET"},
-	{"ZREVRANGE", "key start stop", "ZSET"},
+	{"ZRANGE", "key start stop [WITHSCORES]", "ZSET"},
+	{"ZREVRANGE", "key start stop [WITHSCORES]", "ZSET"},
 	{"ZREM", "key member", "ZSET"},
 	{"ZGETBYRANK", "key rank", "ZSET"},
 	{"ZREVGETBYRANK", "key rank", "ZSET"},
 	{"ZSCORERANGE", "key min max", "ZSET"},
 	{"ZREVSCORERANGE", "key max min", "ZSET"},
+	{"ZKEYEXISTS", "key", "ZSET"},
+	{"ZCLEAR", "key", "ZSET"},
+	{"ZEXPIRE", "key seconds", "ZSET"},
+	{"ZPERSIST", "key", "ZSET"},
+	{"ZTTL", "key", "ZSET"},
+
+	{"KEYS", "pattern", "KEYSPACE"},
+	{"SCAN", "cursor [MATCH pattern] [COUNT count] [TYPE type]", "KEYSPACE"},
+	{"EXISTS", "key [key...]", "KEYSPACE"},
+	{"DEL", "key [key...]", "KEYSPACE"},
+	{"TYPE", "key", "KEYSPACE"},
+	{"DBSIZE", "", "KEYSPACE"},
+	{"FLUSHDB", "", "KEYSPACE"},
+
+	{"PING", "[message]", "CONNECTION"},
+	{"HELLO", "[protover [AUTH username password] [SETNAME clientname]]", "CONNECTION"},
+	{"CLIENT", "ID|GETNAME|SETNAME|SETINFO|INFO|LIST|KILL [args...]", "CONNECTION"},
 }
 
 var host = flag.String("h", "127.0.0.1", "the rosedb server host, default 127.0.0.1")
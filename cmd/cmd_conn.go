@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"errors"
+	"fastdb"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/redcon"
+)
+
+// ServerVersion the version of the server replied by HELLO.
+const ServerVersion = "1.0.0"
+
+// clientInfo the info of a client connection, it is listed by CLIENT LIST.
+type clientInfo struct {
+	id   uint64
+	conn redcon.Conn
+
+	// the fields below are only written by the goroutine of the client, and guarded by mu for the other clients.
+	mu         sync.Mutex
+	name       string
+	libName    string
+	libVer     string
+	resp       int // the protocol version, 2 or 3.
+	createdAt  time.Time
+	lastActive time.Time
+	lastCmd    string
+}
+
+// register the client when the connection is accepted, every client has a unique id.
+// Returns nil if the server is shut down.
+func (s *Server) addClient(conn redcon.Conn) *connContext {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.closing {
+		return nil
+	}
+	s.conns.Add(1)
+
+	ctx := &connContext{}
+	ctx.client.conn = conn
+	ctx.client.resp = 2
+	ctx.client.createdAt = time.Now()
+	ctx.client.lastActive = ctx.client.createdAt
+
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	s.lastClientId++
+	ctx.client.id = s.lastClientId
+	s.clients[ctx.client.id] = ctx
+	conn.SetContext(ctx)
+	return ctx
+}
+
+func (s *Server) removeClient(ctx *connContext) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	delete(s.clients, ctx.client.id)
+	s.conns.Done()
+}
+
+// mapResult the reply of a map, the keys and values are in turn, it is replied as a flat array in RESP2.
+type mapResult [][]byte
+
+// write the reply of a command in the protocol of the client.
+func writeReply(conn redcon.Conn, ctx *connContext, reply interface{}) {
+	conn.WriteRaw(appendReply(nil, ctx.client.resp, reply))
+}
+
+func writeNull(conn redcon.Conn, ctx *connContext) {
+	writeReply(conn, ctx, nil)
+}
+
+// append the reply in the protocol version, the null, double and map have their own types in RESP3.
+func appendReply(b []byte, resp int, reply interface{}) []byte {
+	switch v := reply.(type) {
+	case nil:
+		if resp == 3 {
+			return append(b, "_\r\n"...)
+		}
+		return redcon.AppendNull(b)
+	case redcon.RESP:
+		return append(b, v.Raw...)
+	case float64:
+		if resp == 3 {
+			return append(append(append(b, ','), formatDouble(v)...), "\r\n"...)
+		}
+		return redcon.AppendBulkFloat(b, v)
+	case mapResult:
+		if resp == 3 {
+			b = append(append(append(b, '%'), strconv.Itoa(len(v)/2)...), "\r\n"...)
+		} else {
+			b = redcon.AppendArray(b, len(v))
+		}
+		for _, e := range v {
+			b = redcon.AppendBulk(b, e)
+		}
+		return b
+	case []interface{}:
+		b = redcon.AppendArray(b, len(v))
+		for _, e := range v {
+			b = appendReply(b, resp, e)
+		}
+		return b
+	default:
+		return redcon.AppendAny(b, v)
+	}
+}
+
+// format the double of RESP3, the infinities are inf and -inf.
+func formatDouble(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// handle the connection commands, returns false if it is not a connection command.
+// CLIENT is queued in MULTI like the other commands, but HELLO is refused, because the protocol can not be switched
+// in the middle of the replies of EXEC.
+func (s *Server) handleConnCmd(conn redcon.Conn, ctx *connContext, command string, args []string) bool {
+	var exec ExecCmdFunc
+	switch command {
+	case "hello":
+		if ctx.multi {
+			conn.WriteError(fmt.Sprintf("ERR Command '%s' not allowed inside a transaction", command))
+			return true
+		}
+		exec = func(*fastdb.FastDB, []string) (interface{}, error) {
+			return s.hello(ctx, args)
+		}
+	case "client":
+		if len(args) == 0 {
+			if ctx.multi {
+				ctx.aborted = true
+			}
+			conn.WriteError(newWrongNumOfArgsError(command).Error())
+			return true
+		}
+		exec = func(*fastdb.FastDB, []string) (interface{}, error) {
+			return s.client(ctx, args)
+		}
+	default:
+		return false
+	}
+
+	if ctx.multi {
+		ctx.queue = append(ctx.queue, queuedCmd{exec: exec, args: args})
+		conn.WriteString("QUEUED")
+		return true
+	}
+	reply, err := exec(s.db, args)
+	if err != nil {
+		conn.WriteError(err.Error())
+		return true
+	}
+	writeReply(conn, ctx, reply)
+	return true
+}
+
+// PING [message]
+func ping(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	switch len(args) {
+	case 0:
+		res = redcon.SimpleString("PONG")
+	case 1:
+		res = args[0]
+	default:
+		err = newWrongNumOfArgsError("ping")
+	}
+	return
+}
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+// It switches the protocol of the client, and replies the info of the server.
+func (s *Server) hello(ctx *connContext, args []string) (interface{}, error) {
+	resp := ctx.client.resp
+	var name *string
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, errors.New("ERR Protocol version is not an integer or out of range")
+		}
+		if v != 2 && v != 3 {
+			return nil, errors.New("NOPROTO unsupported protocol version")
+		}
+		resp = v
+
+		for i := 1; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "auth":
+				if i+2 >= len(args) {
+					return nil, ErrSyntaxIncorrect
+				}
+				// there is no password, only the default user can be authenticated.
+				if args[i+1] != "default" {
+					return nil, errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+				}
+				i += 2
+			case "setname":
+				if i+1 >= len(args) {
+					return nil, ErrSyntaxIncorrect
+				}
+				if err := checkClientName(args[i+1]); err != nil {
+					return nil, err
+				}
+				name = &args[i+1]
+				i++
+			default:
+				return nil, ErrSyntaxIncorrect
+			}
+		}
+	}
+
+	ctx.client.mu.Lock()
+	ctx.client.resp = resp
+	if name != nil {
+		ctx.client.name = *name
+	}
+	ctx.client.mu.Unlock()
+
+	var b []byte
+	if resp == 3 {
+		b = append(b, "%7\r\n"...)
+	} else {
+		b = redcon.AppendArray(b, 14)
+	}
+	b = redcon.AppendBulkString(b, "server")
+	b = redcon.AppendBulkString(b, "fastdb")
+	b = redcon.AppendBulkString(b, "version")
+	b = redcon.AppendBulkString(b, ServerVersion)
+	b = redcon.AppendBulkString(b, "proto")
+	b = redcon.AppendInt(b, int64(resp))
+	b = redcon.AppendBulkString(b, "id")
+	b = redcon.AppendInt(b, int64(ctx.client.id))
+	b = redcon.AppendBulkString(b, "mode")
+	b = redcon.AppendBulkString(b, "standalone")
+	b = redcon.AppendBulkString(b, "role")
+	b = redcon.AppendBulkString(b, "master")
+	b = redcon.AppendBulkString(b, "modules")
+	b = redcon.AppendArray(b, 0)
+	return redcon.RESP{Raw: b}, nil
+}
+
+// CLIENT ID|GETNAME|SETNAME|SETINFO|INFO|LIST|KILL
+func (s *Server) client(ctx *connContext, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, newWrongNumOfArgsError("client")
+	}
+
+	sub, args := strings.ToLower(args[0]), args[1:]
+	switch sub {
+	case "id":
+		if len(args) != 0 {
+			return nil, newWrongNumOfArgsError("client|id")
+		}
+		return redcon.SimpleInt(ctx.client.id), nil
+	case "getname":
+		if len(args) != 0 {
+			return nil, newWrongNumOfArgsError("client|getname")
+		}
+		ctx.client.mu.Lock()
+		defer ctx.client.mu.Unlock()
+		if ctx.client.name == "" {
+			return nil, nil
+		}
+		return ctx.client.name, nil
+	case "setname":
+		if len(args) != 1 {
+			return nil, newWrongNumOfArgsError("client|setname")
+		}
+		if err := checkClientName(args[0]); err != nil {
+			return nil, err
+		}
+		ctx.client.mu.Lock()
+		ctx.client.name = args[0]
+		ctx.client.mu.Unlock()
+		return okResult, nil
+	case "setinfo":
+		if len(args) != 2 {
+			return nil, newWrongNumOfArgsError("client|setinfo")
+		}
+		if err := checkClientName(args[1]); err != nil {
+			return nil, err
+		}
+		ctx.client.mu.Lock()
+		defer ctx.client.mu.Unlock()
+		switch strings.ToLower(args[0]) {
+		case "lib-name":
+			ctx.client.libName = args[1]
+		case "lib-ver":
+			ctx.client.libVer = args[1]
+		default:
+			return nil, fmt.Errorf("ERR Unrecognized option '%s'", args[0])
+		}
+		return okResult, nil
+	case "info":
+		if len(args) != 0 {
+			return nil, newWrongNumOfArgsError("client|info")
+		}
+		return ctx.client.String() + "\n", nil
+	case "list":
+		if len(args) != 0 {
+			return nil, ErrSyntaxIncorrect
+		}
+		var b strings.Builder
+		for _, c := range s.listClients() {
+			b.WriteString(c.client.String())
+			b.WriteString("\n")
+		}
+		return b.String(), nil
+	case "kill":
+		return s.killClients(ctx, args)
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'", sub)
+	}
+}
+
+// CLIENT KILL ip:port, or CLIENT KILL [ID client-id] [ADDR ip:port] [SKIPME yes/no].
+// The old form replies OK, the new form replies the number of clients killed.
+func (s *Server) killClients(ctx *connContext, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, newWrongNumOfArgsError("client|kill")
+	}
+
+	if len(args) == 1 {
+		for _, c := range s.listClients() {
+			if c.client.conn.RemoteAddr() == args[0] {
+				s.killClient(ctx, c)
+				return okResult, nil
+			}
+		}
+		return nil, errors.New("ERR No such client")
+	}
+	if len(args)%2 != 0 {
+		return nil, ErrSyntaxIncorrect
+	}
+
+	var id uint64
+	var addr string
+	skipMe := true
+	for i := 0; i < len(args); i += 2 {
+		switch strings.ToLower(args[i]) {
+		case "id":
+			v, err := strconv.ParseUint(args[i+1], 10, 64)
+			if err != nil {
+				return nil, errors.New("ERR client-id should be greater than 0")
+			}
+			id = v
+		case "addr":
+			addr = args[i+1]
+		case "skipme":
+			switch strings.ToLower(args[i+1]) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return nil, ErrSyntaxIncorrect
+			}
+		default:
+			return nil, ErrSyntaxIncorrect
+		}
+	}
+
+	var killed int
+	for _, c := range s.listClients() {
+		if (id != 0 && c.client.id != id) || (addr != "" && c.client.conn.RemoteAddr() != addr) {
+			continue
+		}
+		if skipMe && c == ctx {
+			continue
+		}
+		s.killClient(ctx, c)
+		killed++
+	}
+	return redcon.SimpleInt(killed), nil
+}
+
+// close the connection of the client.
+// The connection of another client is closed by its net.Conn, which is safe in any goroutine, then the connection exits
+// in its own goroutine. Closing the redcon.Conn of another client races with its running command.
+func (s *Server) killClient(ctx, target *connContext) {
+	if target == ctx {
+		_ = target.client.conn.Close()
+		return
+	}
+	_ = target.client.conn.NetConn().Close()
+}
+
+// returns the clients ordered by id.
+func (s *Server) listClients() []*connContext {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	clients := make([]*connContext, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].client.id < clients[j].client.id
+	})
+	return clients
+}
+
+func (c *clientInfo) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	return fmt.Sprintf("id=%d addr=%s name=%s age=%d idle=%d db=0 cmd=%s resp=%d lib-name=%s lib-ver=%s",
+		c.id, c.conn.RemoteAddr(), c.name, int(now.Sub(c.createdAt).Seconds()), int(now.Sub(c.lastActive).Seconds()),
+		c.lastCmd, c.resp, c.libName, c.libVer)
+}
+
+// the client name can not contain spaces, newlines or special characters, the same as redis.
+func checkClientName(name string) error {
+	for _, ch := range name {
+		if ch < '!' || ch > '~' {
+			return errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
+	}
+	return nil
+}
+
+func init() {
+	addExecCommand("ping", ping, -1)
+}
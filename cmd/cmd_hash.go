@@ -18,22 +18,103 @@ func hSet(db *fastdb.FastDB, args []string) (res interface{}, err error) {
 	return
 }
 
+func hSetNx(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("hsetnx")
+		return
+	}
+	var count int
+	if count, err = db.HSetNx([]byte(args[0]), []byte(args[1]), []byte(args[2])); err == nil {
+		res = redcon.SimpleInt(count)
+	}
+	return
+}
+
 func hGet(db *fastdb.FastDB, args []string) (res interface{}, err error) {
 	if len(args) != 2 {
-		err = ErrSyntaxIncorrect
+		err = newWrongNumOfArgsError("hget")
 		return
 	}
-	val := db.HGet([]byte(args[0]), []byte(args[1]))
-	if len(val) == 0 {
-		res = nil
-	} else {
-		res = string(val)
+	res = bulkResult(db.HGet([]byte(args[0]), []byte(args[1])))
+	return
+}
+
+func hGetAll(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("hgetall")
+		return
+	}
+	var values [][]byte
+	if values, err = db.HGetAll([]byte(args[0])); err == nil {
+		res = mapResult(values)
 	}
 	return
 }
 
-func init() {
-	addExecCommand("hset", hSet)
-	addExecCommand("hget", hGet)
+func hDel(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 2 {
+		err = newWrongNumOfArgsError("hdel")
+		return
+	}
+	var count int
+	if count, err = db.HDel([]byte(args[0]), toBytesList(args[1:])...); err == nil {
+		res = redcon.SimpleInt(count)
+	}
+	return
+}
 
+func hExists(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("hexists")
+		return
+	}
+	res = boolResult(db.HExists([]byte(args[0]), []byte(args[1])))
+	return
+}
+
+func hLen(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("hlen")
+		return
+	}
+	res = redcon.SimpleInt(db.HLen([]byte(args[0])))
+	return
+}
+
+func hKeys(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("hkeys")
+		return
+	}
+	res = db.HKeys([]byte(args[0]))
+	return
+}
+
+func hVals(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("hvals")
+		return
+	}
+	var values [][]byte
+	if values, err = db.HVals([]byte(args[0])); err == nil {
+		res = values
+	}
+	return
+}
+
+func init() {
+	addExecCommand("hset", hSet, 4)
+	addExecCommand("hsetnx", hSetNx, 4)
+	addExecCommand("hget", hGet, 3)
+	addExecCommand("hgetall", hGetAll, 2)
+	addExecCommand("hdel", hDel, -3)
+	addExecCommand("hexists", hExists, 3)
+	addExecCommand("hlen", hLen, 2)
+	addExecCommand("hkeys", hKeys, 2)
+	addExecCommand("hvals", hVals, 2)
+	addExecCommand("hkeyexists", newKeyExistsCmd("hkeyexists", (*fastdb.FastDB).HKeyExists), 2)
+	addExecCommand("hclear", newClearCmd("hclear", (*fastdb.FastDB).HClear), 2)
+	addExecCommand("hexpire", newExpireCmd("hexpire", (*fastdb.FastDB).HExpire), 3)
+	addExecCommand("hpersist", newPersistCmd("hpersist", (*fastdb.FastDB).HTTL, (*fastdb.FastDB).HPersist), 2)
+	addExecCommand("httl", newTTLCmd("httl", (*fastdb.FastDB).HTTL), 2)
 }
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fastdb"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+)
+
+// the names of the data types in TYPE and SCAN, the same as redis.
+var typeNames = map[fastdb.DataType]string{
+	fastdb.String: "string",
+	fastdb.List:   "list",
+	fastdb.Hash:   "hash",
+	fastdb.Set:    "set",
+	fastdb.ZSet:   "zset",
+}
+
+func keys(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("keys")
+		return
+	}
+	res = db.Keys(args[0])
+	return
+}
+
+func scan(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 1 || len(args)%2 == 0 {
+		err = newWrongNumOfArgsError("scan")
+		return
+	}
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var opts fastdb.ScanOptions
+	for i := 1; i < len(args); i += 2 {
+		switch strings.ToLower(args[i]) {
+		case "match":
+			opts.Match = args[i+1]
+		case "count":
+			if opts.Count, err = toInt(args[i+1]); err != nil {
+				return
+			}
+			if opts.Count <= 0 {
+				return nil, ErrSyntaxIncorrect
+			}
+		case "type":
+			// the unknown type matches nothing, like redis.
+			dType, ok := toDataType(args[i+1])
+			if !ok {
+				return []interface{}{"0", [][]byte{}}, nil
+			}
+			opts.Types = append(opts.Types, dType)
+		default:
+			return nil, ErrSyntaxIncorrect
+		}
+	}
+
+	next, keys := db.Scan(cursor, opts)
+	if keys == nil {
+		keys = [][]byte{}
+	}
+	res = []interface{}{strconv.FormatUint(next, 10), keys}
+	return
+}
+
+func exists(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 1 {
+		err = newWrongNumOfArgsError("exists")
+		return
+	}
+	res = redcon.SimpleInt(db.Exists(toBytesList(args)...))
+	return
+}
+
+func del(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 1 {
+		err = newWrongNumOfArgsError("del")
+		return
+	}
+	var count int
+	if count, err = db.Del(toBytesList(args)...); err == nil {
+		res = redcon.SimpleInt(count)
+	}
+	return
+}
+
+func keyType(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("type")
+		return
+	}
+	dType, ok := db.Type([]byte(args[0]))
+	if !ok {
+		return redcon.SimpleString("none"), nil
+	}
+	res = redcon.SimpleString(typeNames[dType])
+	return
+}
+
+func dbSize(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 0 {
+		err = newWrongNumOfArgsError("dbsize")
+		return
+	}
+	res = redcon.SimpleInt(db.DBSize())
+	return
+}
+
+func flushDB(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 0 {
+		err = newWrongNumOfArgsError("flushdb")
+		return
+	}
+	if err = db.FlushDB(); err == nil {
+		res = okResult
+	}
+	return
+}
+
+func toDataType(name string) (fastdb.DataType, bool) {
+	for dType, n := range typeNames {
+		if strings.EqualFold(n, name) {
+			return dType, true
+		}
+	}
+	return 0, false
+}
+
+func init() {
+	addExecCommand("keys", keys, 2)
+	addExecCommand("scan", scan, -2)
+	addExecCommand("exists", exists, -2)
+	addExecCommand("del", del, -2)
+	addExecCommand("type", keyType, 2)
+	addExecCommand("dbsize", dbSize, 1)
+	addExecCommand("flushdb", flushDB, 1)
+}
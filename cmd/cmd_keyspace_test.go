@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fastdb"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	cfg := fastdb.DefaultConfig()
+	cfg.DirPath = t.TempDir()
+	db, err := fastdb.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	for i := 0; i < 30; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%d", i)), []byte("val"))
+	}
+
+	seen := make(map[string]int)
+	cursor := "0"
+	for {
+		res, err := scan(db, []string{cursor, "COUNT", "7"})
+		assert.Nil(t, err)
+		reply := res.([]interface{})
+		for _, k := range reply[1].([][]byte) {
+			seen[string(k)]++
+		}
+		if cursor = reply[0].(string); cursor == "0" {
+			break
+		}
+	}
+	assert.Equal(t, 30, len(seen))
+	for k, n := range seen {
+		assert.Equal(t, 1, n, k)
+	}
+
+	// the cursor saves the position, so it is still valid after reopening.
+	res, err := scan(db, []string{"0", "COUNT", "7"})
+	assert.Nil(t, err)
+	cursor = res.([]interface{})[0].(string)
+	for _, k := range res.([]interface{})[1].([][]byte) {
+		seen[string(k)]++
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = fastdb.Reopen(cfg.DirPath); err != nil {
+		t.Fatal(err)
+	}
+	for cursor != "0" {
+		res, err = scan(db, []string{cursor, "COUNT", "7"})
+		assert.Nil(t, err)
+		reply := res.([]interface{})
+		for _, k := range reply[1].([][]byte) {
+			seen[string(k)]++
+		}
+		cursor = reply[0].(string)
+	}
+	for k, n := range seen {
+		assert.Equal(t, 2, n, k)
+	}
+
+	_, err = scan(db, []string{"abc"})
+	assert.Equal(t, ErrInvalidCursor, err)
+}
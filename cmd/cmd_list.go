@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fastdb"
+	"fastdb/ds/list"
+	"strings"
+
+	"github.com/tidwall/redcon"
+)
+
+func newPushCmd(cmd string, push func(db *fastdb.FastDB, key []byte, values ...[]byte) (int, error)) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) < 2 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		var length int
+		if length, err = push(db, []byte(args[0]), toBytesList(args[1:])...); err == nil {
+			res = redcon.SimpleInt(length)
+		}
+		return
+	}
+}
+
+func newPopCmd(cmd string, pop func(db *fastdb.FastDB, key []byte) ([]byte, error)) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 1 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		var val []byte
+		if val, err = pop(db, []byte(args[0])); keyNotExist(err) {
+			err = nil
+		}
+		if err == nil {
+			res = bulkResult(val)
+		}
+		return
+	}
+}
+
+func lIndex(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("lindex")
+		return
+	}
+	var index int
+	if index, err = toInt(args[1]); err != nil {
+		return
+	}
+	res = bulkResult(db.LIndex([]byte(args[0]), index))
+	return
+}
+
+func lRem(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("lrem")
+		return
+	}
+	var count, removed int
+	if count, err = toInt(args[2]); err != nil {
+		return
+	}
+	if removed, err = db.LRem([]byte(args[0]), []byte(args[1]), count); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = redcon.SimpleInt(removed)
+	}
+	return
+}
+
+func lInsert(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 4 {
+		err = newWrongNumOfArgsError("linsert")
+		return
+	}
+	var option list.InsertOption
+	switch strings.ToLower(args[1]) {
+	case "before":
+		option = list.Before
+	case "after":
+		option = list.After
+	default:
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var length int
+	if length, err = db.LInsert([]byte(args[0]), option, []byte(args[2]), []byte(args[3])); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = redcon.SimpleInt(length)
+	}
+	return
+}
+
+func lSet(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("lset")
+		return
+	}
+	var index int
+	if index, err = toInt(args[1]); err != nil {
+		return
+	}
+	var ok bool
+	if ok, err = db.LSet([]byte(args[0]), index, []byte(args[2])); keyNotExist(err) {
+		err = ErrNoSuchKey
+	}
+	if err != nil {
+		return
+	}
+	if !ok {
+		if db.LKeyExists([]byte(args[0])) {
+			err = ErrIndexOutOfRange
+		} else {
+			err = ErrNoSuchKey
+		}
+		return
+	}
+	res = okResult
+	return
+}
+
+func lTrim(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("ltrim")
+		return
+	}
+	var start, end int
+	if start, err = toInt(args[1]); err != nil {
+		return
+	}
+	if end, err = toInt(args[2]); err != nil {
+		return
+	}
+	if err = db.LTrim([]byte(args[0]), start, end); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = okResult
+	}
+	return
+}
+
+func lRange(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("lrange")
+		return
+	}
+	var start, end int
+	if start, err = toInt(args[1]); err != nil {
+		return
+	}
+	if end, err = toInt(args[2]); err != nil {
+		return
+	}
+	var values [][]byte
+	if values, err = db.LRange([]byte(args[0]), start, end); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = values
+	}
+	return
+}
+
+func lLen(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("llen")
+		return
+	}
+	res = redcon.SimpleInt(db.LLen([]byte(args[0])))
+	return
+}
+
+func lValExists(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("lvalexists")
+		return
+	}
+	res = boolResult(db.LValExists([]byte(args[0]), []byte(args[1])))
+	return
+}
+
+func init() {
+	addExecCommand("lpush", newPushCmd("lpush", (*fastdb.FastDB).LPush), -3)
+	addExecCommand("rpush", newPushCmd("rpush", (*fastdb.FastDB).RPush), -3)
+	addExecCommand("lpop", newPopCmd("lpop", (*fastdb.FastDB).LPop), 2)
+	addExecCommand("rpop", newPopCmd("rpop", (*fastdb.FastDB).RPop), 2)
+	addExecCommand("lindex", lIndex, 3)
+	addExecCommand("lrem", lRem, 4)
+	addExecCommand("linsert", lInsert, 5)
+	addExecCommand("lset", lSet, 4)
+	addExecCommand("ltrim", lTrim, 4)
+	addExecCommand("lrange", lRange, 4)
+	addExecCommand("llen", lLen, 2)
+	addExecCommand("lkeyexists", newKeyExistsCmd("lkeyexists", (*fastdb.FastDB).LKeyExists), 2)
+	addExecCommand("lvalexists", lValExists, 3)
+	addExecCommand("lclear", newClearCmd("lclear", (*fastdb.FastDB).LClear), 2)
+	addExecCommand("lexpire", newExpireCmd("lexpire", (*fastdb.FastDB).LExpire), 3)
+	addExecCommand("lpersist", newPersistCmd("lpersist", (*fastdb.FastDB).LTTL, (*fastdb.FastDB).LPersist), 2)
+	addExecCommand("lttl", newTTLCmd("lttl", (*fastdb.FastDB).LTTL), 2)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fastdb"
+
+	"github.com/tidwall/redcon"
+)
+
+func sAdd(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 2 {
+		err = newWrongNumOfArgsError("sadd")
+		return
+	}
+	var count int
+	if count, err = db.SAdd([]byte(args[0]), toBytesList(args[1:])...); err == nil {
+		res = redcon.SimpleInt(count)
+	}
+	return
+}
+
+func sPop(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("spop")
+		return
+	}
+	var count int
+	if count, err = toInt(args[1]); err != nil {
+		return
+	}
+	var values [][]byte
+	if values, err = db.SPop([]byte(args[0]), count); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = values
+	}
+	return
+}
+
+func sIsMember(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("sismember")
+		return
+	}
+	res = boolResult(db.SIsMember([]byte(args[0]), []byte(args[1])))
+	return
+}
+
+func sRandMember(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("srandmember")
+		return
+	}
+	var count int
+	if count, err = toInt(args[1]); err != nil {
+		return
+	}
+	res = db.SRandMember([]byte(args[0]), count)
+	return
+}
+
+func sRem(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 2 {
+		err = newWrongNumOfArgsError("srem")
+		return
+	}
+	var count int
+	if count, err = db.SRem([]byte(args[0]), toBytesList(args[1:])...); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = redcon.SimpleInt(count)
+	}
+	return
+}
+
+func sMove(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("smove")
+		return
+	}
+	var ok bool
+	if ok, err = db.SMove([]byte(args[0]), []byte(args[1]), []byte(args[2])); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = boolResult(ok)
+	}
+	return
+}
+
+func sCard(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("scard")
+		return
+	}
+	res = redcon.SimpleInt(db.SCard([]byte(args[0])))
+	return
+}
+
+func sMembers(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("smembers")
+		return
+	}
+	res = db.SMembers([]byte(args[0]))
+	return
+}
+
+func sUnion(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 1 {
+		err = newWrongNumOfArgsError("sunion")
+		return
+	}
+	res = db.SUnion(toBytesList(args)...)
+	return
+}
+
+func sDiff(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) < 1 {
+		err = newWrongNumOfArgsError("sdiff")
+		return
+	}
+	res = db.SDiff(toBytesList(args)...)
+	return
+}
+
+func init() {
+	addExecCommand("sadd", sAdd, -3)
+	addExecCommand("spop", sPop, 3)
+	addExecCommand("sismember", sIsMember, 3)
+	addExecCommand("srandmember", sRandMember, 3)
+	addExecCommand("srem", sRem, -3)
+	addExecCommand("smove", sMove, 4)
+	addExecCommand("scard", sCard, 2)
+	addExecCommand("smembers", sMembers, 2)
+	addExecCommand("sunion", sUnion, -2)
+	addExecCommand("sdiff", sDiff, -2)
+	addExecCommand("skeyexists", newKeyExistsCmd("skeyexists", (*fastdb.FastDB).SKeyExists), 2)
+	addExecCommand("sclear", newClearCmd("sclear", (*fastdb.FastDB).SClear), 2)
+	addExecCommand("sexpire", newExpireCmd("sexpire", (*fastdb.FastDB).SExpire), 3)
+	addExecCommand("spersist", newPersistCmd("spersist", (*fastdb.FastDB).STTL, (*fastdb.FastDB).SPersist), 2)
+	addExecCommand("sttl", newTTLCmd("sttl", (*fastdb.FastDB).STTL), 2)
+}
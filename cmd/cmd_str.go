@@ -4,18 +4,156 @@ import (
 	"errors"
 	"fastdb"
 	"fmt"
+	"strconv"
 
 	"github.com/tidwall/redcon"
 )
 
-// ErrSyntaxIncorrect incorrect err
-var ErrSyntaxIncorrect = errors.New("syntax err")
+var (
+	// ErrSyntaxIncorrect incorrect err
+	ErrSyntaxIncorrect = errors.New("syntax err")
+
+	// ErrValueNotInteger the argument is not an integer
+	ErrValueNotInteger = errors.New("value is not an integer or out of range")
+
+	// ErrValueNotFloat the argument is not a float
+	ErrValueNotFloat = errors.New("value is not a valid float")
+
+	// ErrNoSuchKey the key does not exist
+	ErrNoSuchKey = errors.New("no such key")
+
+	// ErrIndexOutOfRange the index of the list is out of range
+	ErrIndexOutOfRange = errors.New("index out of range")
+
+	// ErrInvalidCursor the cursor of scan is not valid
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
 var okResult = redcon.SimpleString("OK")
 
 func newWrongNumOfArgsError(cmd string) error {
 	return fmt.Errorf("wrong number of arguments for '%s' command", cmd)
 }
 
+func toBytesList(args []string) [][]byte {
+	res := make([][]byte, len(args))
+	for i, arg := range args {
+		res[i] = []byte(arg)
+	}
+	return res
+}
+
+func toInt(arg string) (int, error) {
+	v, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, ErrValueNotInteger
+	}
+	return v, nil
+}
+
+func toFloat(arg string) (float64, error) {
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, ErrValueNotFloat
+	}
+	return v, nil
+}
+
+func boolResult(ok bool) redcon.SimpleInt {
+	if ok {
+		return redcon.SimpleInt(1)
+	}
+	return redcon.SimpleInt(0)
+}
+
+// the value is replied as a bulk string, or nil if it does not exist.
+func bulkResult(val []byte) interface{} {
+	if val == nil {
+		return nil
+	}
+	return string(val)
+}
+
+// the expired key is treated as not existing, like redis.
+func keyNotExist(err error) bool {
+	return err == fastdb.ErrKeyNotExist || err == fastdb.ErrKeyExpired
+}
+
+// the not existing or expired key is replied as 0, the same as redis.
+func expireResult(err error) (interface{}, error) {
+	if keyNotExist(err) {
+		return redcon.SimpleInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return redcon.SimpleInt(1), nil
+}
+
+func newExpireCmd(cmd string, expire func(db *fastdb.FastDB, key []byte, duration int64) error) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 2 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		var duration int
+		if duration, err = toInt(args[1]); err != nil {
+			return
+		}
+		return expireResult(expire(db, []byte(args[0]), int64(duration)))
+	}
+}
+
+// the persist command replies 1 only if the timeout is removed.
+func newPersistCmd(cmd string, ttl func(db *fastdb.FastDB, key []byte) int64, persist func(db *fastdb.FastDB, key []byte) error) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 1 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		if ttl(db, []byte(args[0])) < 0 {
+			res = redcon.SimpleInt(0)
+			return
+		}
+		return expireResult(persist(db, []byte(args[0])))
+	}
+}
+
+func newTTLCmd(cmd string, ttl func(db *fastdb.FastDB, key []byte) int64) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 1 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		res = redcon.SimpleInt(ttl(db, []byte(args[0])))
+		return
+	}
+}
+
+func newKeyExistsCmd(cmd string, exists func(db *fastdb.FastDB, key []byte) bool) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 1 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		res = boolResult(exists(db, []byte(args[0])))
+		return
+	}
+}
+
+func newClearCmd(cmd string, clear func(db *fastdb.FastDB, key []byte) error) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 1 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		if err = clear(db, []byte(args[0])); err == nil {
+			res = okResult
+		}
+		return
+	}
+}
+
 func set(db *fastdb.FastDB, args []string) (res interface{}, err error) {
 	if len(args) != 2 {
 		err = newWrongNumOfArgsError("set")
@@ -39,6 +177,61 @@ func get(db *fastdb.FastDB, args []string) (res interface{}, err error) {
 	if val, err = db.Get([]byte(key)); err == nil {
 		res = string(val)
 	}
+	// the not existing key is replied as nil.
+	if keyNotExist(err) {
+		res, err = nil, nil
+	}
+	return
+}
+
+func setEx(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("setex")
+		return
+	}
+	var duration int
+	if duration, err = toInt(args[1]); err != nil {
+		return
+	}
+	if err = db.SetEx([]byte(args[0]), []byte(args[2]), int64(duration)); err == nil {
+		res = okResult
+	}
+	return
+}
+
+func setNx(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("setnx")
+		return
+	}
+	var ok bool
+	if ok, err = db.SetNx([]byte(args[0]), []byte(args[1])); err == nil {
+		res = boolResult(ok)
+	}
+	return
+}
+
+func getSet(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("getset")
+		return
+	}
+	var val []byte
+	if val, err = db.GetSet([]byte(args[0]), []byte(args[1])); err == nil {
+		res = bulkResult(val)
+	}
+	return
+}
+
+func appendStr(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("append")
+		return
+	}
+	var length int
+	if length, err = db.Append([]byte(args[0]), []byte(args[1])); err == nil {
+		res = redcon.SimpleInt(length)
+	}
 	return
 }
 
@@ -76,7 +269,50 @@ func strRem(db *fastdb.FastDB, args []string) (res interface{}, err error) {
 	return
 }
 
+func prefixScan(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("prefixscan")
+		return
+	}
+	var limit, offset int
+	if limit, err = toInt(args[1]); err != nil {
+		return
+	}
+	if offset, err = toInt(args[2]); err != nil {
+		return
+	}
+	var values [][]byte
+	if values, err = db.PrefixScan(args[0], limit, offset); err == nil {
+		res = values
+	}
+	return
+}
+
+func rangeScan(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("rangescan")
+		return
+	}
+	var values [][]byte
+	if values, err = db.RangeScan([]byte(args[0]), []byte(args[1])); err == nil {
+		res = values
+	}
+	return
+}
+
 func init() {
-	addExecCommand("set", set)
-	addExecCommand("get", get)
+	addExecCommand("set", set, 3)
+	addExecCommand("get", get, 2)
+	addExecCommand("setex", setEx, 4)
+	addExecCommand("setnx", setNx, 3)
+	addExecCommand("getset", getSet, 3)
+	addExecCommand("append", appendStr, 3)
+	addExecCommand("strlen", strLen, 2)
+	addExecCommand("strexists", strExists, 2)
+	addExecCommand("strrem", strRem, 2)
+	addExecCommand("prefixscan", prefixScan, 4)
+	addExecCommand("rangescan", rangeScan, 3)
+	addExecCommand("expire", newExpireCmd("expire", (*fastdb.FastDB).Expire), 3)
+	addExecCommand("persist", newPersistCmd("persist", (*fastdb.FastDB).TTL, (*fastdb.FastDB).Persist), 2)
+	addExecCommand("ttl", newTTLCmd("ttl", (*fastdb.FastDB).TTL), 2)
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fastdb"
+	"strings"
+
+	"github.com/tidwall/redcon"
+)
+
+func zAdd(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("zadd")
+		return
+	}
+	var score float64
+	if score, err = toFloat(args[1]); err != nil {
+		return
+	}
+	var added int
+	if added, err = db.ZAdd([]byte(args[0]), score, []byte(args[2])); err == nil {
+		res = redcon.SimpleInt(added)
+	}
+	return
+}
+
+func zScore(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("zscore")
+		return
+	}
+	if ok, score := db.ZScore([]byte(args[0]), []byte(args[1])); ok {
+		res = score
+	}
+	return
+}
+
+func zCard(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 1 {
+		err = newWrongNumOfArgsError("zcard")
+		return
+	}
+	res = redcon.SimpleInt(db.ZCard([]byte(args[0])))
+	return
+}
+
+func newZRankCmd(cmd string, rank func(db *fastdb.FastDB, key, member []byte) int64) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 2 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		if r := rank(db, []byte(args[0]), []byte(args[1])); r >= 0 {
+			res = redcon.SimpleInt(r)
+		}
+		return
+	}
+}
+
+func zIncrBy(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 3 {
+		err = newWrongNumOfArgsError("zincrby")
+		return
+	}
+	var increment, score float64
+	if increment, err = toFloat(args[1]); err != nil {
+		return
+	}
+	if score, err = db.ZIncrBy([]byte(args[0]), increment, []byte(args[2])); err == nil {
+		res = score
+	}
+	return
+}
+
+// the range commands accept the WITHSCORES option.
+func newZRangeCmd(cmd string, zRange, withScores func(db *fastdb.FastDB, key []byte, start, stop int) []interface{}) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 3 && len(args) != 4 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		var start, stop int
+		if start, err = toInt(args[1]); err != nil {
+			return
+		}
+		if stop, err = toInt(args[2]); err != nil {
+			return
+		}
+
+		if len(args) == 3 {
+			res = zRange(db, []byte(args[0]), start, stop)
+		} else if strings.ToLower(args[3]) == "withscores" {
+			res = withScores(db, []byte(args[0]), start, stop)
+		} else {
+			err = ErrSyntaxIncorrect
+		}
+		return
+	}
+}
+
+func zRem(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+	if len(args) != 2 {
+		err = newWrongNumOfArgsError("zrem")
+		return
+	}
+	var ok bool
+	if ok, err = db.ZRem([]byte(args[0]), []byte(args[1])); keyNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		res = boolResult(ok)
+	}
+	return
+}
+
+// the reply is the member and its score, or an empty array if the rank is out of range.
+func newZGetByRankCmd(cmd string, getByRank func(db *fastdb.FastDB, key []byte, rank int) []interface{}) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 2 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		var rank int
+		if rank, err = toInt(args[1]); err != nil {
+			return
+		}
+		val := getByRank(db, []byte(args[0]), rank)
+		if len(val) == 2 && val[0] == "" {
+			val = nil
+		}
+		res = val
+		return
+	}
+}
+
+func newZScoreRangeCmd(cmd string, scoreRange func(db *fastdb.FastDB, key []byte, from, to float64) []interface{}) ExecCmdFunc {
+	return func(db *fastdb.FastDB, args []string) (res interface{}, err error) {
+		if len(args) != 3 {
+			err = newWrongNumOfArgsError(cmd)
+			return
+		}
+		var from, to float64
+		if from, err = toFloat(args[1]); err != nil {
+			return
+		}
+		if to, err = toFloat(args[2]); err != nil {
+			return
+		}
+		res = scoreRange(db, []byte(args[0]), from, to)
+		return
+	}
+}
+
+func init() {
+	addExecCommand("zadd", zAdd, 4)
+	addExecCommand("zscore", zScore, 3)
+	addExecCommand("zcard", zCard, 2)
+	addExecCommand("zrank", newZRankCmd("zrank", (*fastdb.FastDB).ZRank), 3)
+	addExecCommand("zrevrank", newZRankCmd("zrevrank", (*fastdb.FastDB).ZRevRank), 3)
+	addExecCommand("zincrby", zIncrBy, 4)
+	addExecCommand("zrange", newZRangeCmd("zrange", (*fastdb.FastDB).ZRange, (*fastdb.FastDB).ZRangeWithScores), -4)
+	addExecCommand("zrevrange", newZRangeCmd("zrevrange", (*fastdb.FastDB).ZRevRange, (*fastdb.FastDB).ZRevRangeWithScores), -4)
+	addExecCommand("zrem", zRem, 3)
+	addExecCommand("zgetbyrank", newZGetByRankCmd("zgetbyrank", (*fastdb.FastDB).ZGetByRank), 3)
+	addExecCommand("zrevgetbyrank", newZGetByRankCmd("zrevgetbyrank", (*fastdb.FastDB).ZRevGetByRank), 3)
+	addExecCommand("zscorerange", newZScoreRangeCmd("zscorerange", (*fastdb.FastDB).ZScoreRange), 4)
+	addExecCommand("zrevscorerange", newZScoreRangeCmd("zrevscorerange", (*fastdb.FastDB).ZRevScoreRange), 4)
+	addExecCommand("zkeyexists", newKeyExistsCmd("zkeyexists", (*fastdb.FastDB).ZKeyExists), 2)
+	addExecCommand("zclear", newClearCmd("zclear", (*fastdb.FastDB).ZClear), 2)
+	addExecCommand("zexpire", newExpireCmd("zexpire", (*fastdb.FastDB).ZExpire), 3)
+	addExecCommand("zpersist", newPersistCmd("zpersist", (*fastdb.FastDB).ZTTL, (*fastdb.FastDB).ZPersist), 2)
+	addExecCommand("zttl", newTTLCmd("zttl", (*fastdb.FastDB).ZTTL), 2)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fastdb"
+	"fastdb/storage"
+	"flag"
+	"log"
+)
+
+var dirPath = flag.String("dir", fastdb.DefaultDirPath, "the data directory of fastdb, the db must be closed")
+
+// upgrade the db files written by the old versions to the current format.
+func main() {
+	flag.Parse()
+
+	migrated, err := fastdb.Migrate(*dirPath)
+	if err != nil {
+		log.Fatalf("migrate the db files err: %+v\n", err)
+	}
+	if migrated == 0 {
+		log.Println("all the db files are in the current format, nothing to migrate.")
+		return
+	}
+	log.Printf("%d db files are migrated to format version %d.\n", migrated, storage.FormatVersion)
+}
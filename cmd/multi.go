@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fastdb"
+
+	"github.com/tidwall/redcon"
+)
+
+// connContext the state of a client connection.
+type connContext struct {
+	multi   bool        // in MULTI, the commands are queued until EXEC.
+	aborted bool        // an error occurred when queueing, EXEC will discard the transaction.
+	queue   []queuedCmd // the queued commands.
+	watcher *fastdb.Watcher
+	client  clientInfo // the info of the client, for the HELLO and CLIENT commands.
+}
+
+type queuedCmd struct {
+	exec ExecCmdFunc
+	args []string
+}
+
+// get the state of the connection, it is created when the connection is accepted.
+func getConnContext(conn redcon.Conn) *connContext {
+	ctx, ok := conn.Context().(*connContext)
+	if !ok {
+		ctx = &connContext{}
+		ctx.client.conn = conn
+		conn.SetContext(ctx)
+	}
+	return ctx
+}
+
+// reset the transaction state and unwatch all the keys.
+func (ctx *connContext) reset() {
+	ctx.multi, ctx.aborted, ctx.queue = false, false, nil
+	if ctx.watcher != nil {
+		ctx.watcher.Close()
+		ctx.watcher = nil
+	}
+}
+
+// handle the transaction commands, returns false if it is not a transaction command.
+func (s *Server) handleTxnCmd(conn redcon.Conn, ctx *connContext, command string, args []string) bool {
+	switch command {
+	case "multi":
+		if len(args) != 0 {
+			conn.WriteError(newWrongNumOfArgsError(command).Error())
+		} else if ctx.multi {
+			conn.WriteError("ERR MULTI calls can not be nested")
+		} else {
+			ctx.multi = true
+			conn.WriteAny(okResult)
+		}
+	case "exec":
+		if len(args) != 0 {
+			conn.WriteError(newWrongNumOfArgsError(command).Error())
+			return true
+		}
+		if !ctx.multi {
+			conn.WriteError("ERR EXEC without MULTI")
+			return true
+		}
+		s.exec(conn, ctx)
+	case "discard":
+		if len(args) != 0 {
+			conn.WriteError(newWrongNumOfArgsError(command).Error())
+		} else if !ctx.multi {
+			conn.WriteError("ERR DISCARD without MULTI")
+		} else {
+			ctx.reset()
+			conn.WriteAny(okResult)
+		}
+	case "watch":
+		if len(args) == 0 {
+			conn.WriteError(newWrongNumOfArgsError(command).Error())
+			return true
+		}
+		if ctx.multi {
+			conn.WriteError("ERR WATCH inside MULTI is not allowed")
+			return true
+		}
+		keys := make([][]byte, len(args))
+		for i, k := range args {
+			keys[i] = []byte(k)
+		}
+		if ctx.watcher == nil {
+			ctx.watcher = s.db.Watch(keys...)
+		} else {
+			ctx.watcher.Add(keys...)
+		}
+		conn.WriteAny(okResult)
+	case "unwatch":
+		if len(args) != 0 {
+			conn.WriteError(newWrongNumOfArgsError(command).Error())
+			return true
+		}
+		if ctx.watcher != nil {
+			ctx.watcher.Close()
+			ctx.watcher = nil
+		}
+		conn.WriteAny(okResult)
+	default:
+		return false
+	}
+	return true
+}
+
+// execute the queued commands, no other commands can be executed at the same time.
+// A nil array is replied if any of the watched keys has been modified.
+func (s *Server) exec(conn redcon.Conn, ctx *connContext) {
+	defer ctx.reset()
+
+	if ctx.aborted {
+		conn.WriteError("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ctx.watcher != nil && ctx.watcher.Modified() {
+		writeNull(conn, ctx)
+		return
+	}
+
+	conn.WriteArray(len(ctx.queue))
+	for _, c := range ctx.queue {
+		reply, err := c.exec(s.db, c.args)
+		if err != nil {
+			conn.WriteError(err.Error())
+			continue
+		}
+		writeReply(conn, ctx, reply)
+	}
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fastdb"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/tidwall/redcon"
 )
@@ -13,13 +17,45 @@ type ExecCmdFunc func(*fastdb.FastDB, []string) (interface{}, error)
 
 var ExecCmd = make(map[string]ExecCmdFunc)
 
-func addExecCommand(cmd string, cmdFunc ExecCmdFunc) {
+// the number of arguments of the commands, including the command name, -N means at least N, the same as redis.
+var cmdArity = make(map[string]int)
+
+func addExecCommand(cmd string, cmdFunc ExecCmdFunc, arity int) {
 	ExecCmd[strings.ToLower(cmd)] = cmdFunc
+	cmdArity[strings.ToLower(cmd)] = arity
+}
+
+// check the number of arguments of the command, args does not include the command name.
+func checkArity(cmd string, args []string) bool {
+	arity, n := cmdArity[cmd], len(args)+1
+	if arity < 0 {
+		return n >= -arity
+	}
+	return n == arity
 }
 
+// ErrServerClosed the server is shut down
+var ErrServerClosed = errors.New("ERR server is shutting down")
+
+// ErrCloseTimeout the connections don`t exit in time when shutting down the server
+var ErrCloseTimeout = errors.New("the connections are not closed in time")
+
+// the max time of waiting for the running commands after the deadline of shutdown.
+var closeTimeout = 5 * time.Second
+
 type Server struct {
 	server *redcon.Server
 	db     *fastdb.FastDB
+	mu     sync.RWMutex // EXEC holds the write lock, so the queued commands are executed without interleaving.
+
+	stateMu  sync.Mutex
+	closing  bool           // no more connections and commands are accepted after shutdown.
+	inflight sync.WaitGroup // the commands being executed.
+	conns    sync.WaitGroup // the accepted connections.
+
+	clientMu     sync.Mutex
+	clients      map[uint64]*connContext // the connected clients by id.
+	lastClientId uint64
 }
 
 // 创建服务
@@ -28,28 +64,40 @@ func NewServer(config fastdb.Config) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Server{db: db}, nil
+	return &Server{db: db, clients: make(map[uint64]*connContext)}, nil
 }
 
 // 监听服务
 func (s *Server) Listen(addr string) {
-	svr := redcon.NewServerNetwork("tcp", addr,
+	s.stateMu.Lock()
+	if s.closing {
+		s.stateMu.Unlock()
+		return
+	}
+	s.server = redcon.NewServerNetwork("tcp", addr,
 		func(conn redcon.Conn, cmd redcon.Command) {
 			log.Printf("accept: %s", string(cmd.Args[0]))
 			s.handleCmd(conn, cmd)
 		},
 		func(conn redcon.Conn) bool {
+			if s.addClient(conn) == nil {
+				return false
+			}
 			log.Printf("accept: %s", conn.RemoteAddr())
 			return true
 		},
 		func(conn redcon.Conn, err error) {
 			log.Printf("closed: %s, err: %v", conn.RemoteAddr(), err)
+			ctx := getConnContext(conn)
+			ctx.reset()
+			s.removeClient(ctx)
 		},
 	)
+	svr := s.server
+	s.stateMu.Unlock()
 
-	s.server = svr
 	log.Println("rosedb is running, ready to accept connections.")
-	if err := s.server.ListenAndServe(); err != nil {
+	if err := svr.ListenAndServe(); err != nil {
 		log.Printf("listen and serve ocuurs error: %+v", err)
 	}
 }
@@ -61,12 +109,13 @@ func (s *Server) handleCmd(conn redcon.Conn, cmd redcon.Command) {
 		}
 	}()
 
-	command := strings.ToLower(string(cmd.Args[0]))
-	exec, exist := ExecCmd[command]
-	if !exist {
-		conn.WriteError(fmt.Sprintf("ERR unknown command '%s'", command))
+	if !s.begin() {
+		conn.WriteError(ErrServerClosed.Error())
 		return
 	}
+	defer s.inflight.Done()
+
+	command := strings.ToLower(string(cmd.Args[0]))
 	args := make([]string, 0, len(cmd.Args)-1)
 	for i, bytes := range cmd.Args {
 		if i == 0 {
@@ -74,10 +123,122 @@ func (s *Server) handleCmd(conn redcon.Conn, cmd redcon.Command) {
 		}
 		args = append(args, string(bytes))
 	}
-	reply, err := exec(s.db, args)
+
+	ctx := getConnContext(conn)
+	ctx.client.mu.Lock()
+	ctx.client.lastCmd, ctx.client.lastActive = command, time.Now()
+	ctx.client.mu.Unlock()
+
+	if s.handleConnCmd(conn, ctx, command, args) {
+		return
+	}
+	if s.handleTxnCmd(conn, ctx, command, args) {
+		return
+	}
+
+	// the unknown command and the wrong number of arguments are checked before queueing,
+	// and the transaction will be discarded by EXEC.
+	exec, exist := ExecCmd[command]
+	if !exist {
+		if ctx.multi {
+			ctx.aborted = true
+		}
+		conn.WriteError(fmt.Sprintf("ERR unknown command '%s'", command))
+		return
+	}
+	if !checkArity(command, args) {
+		if ctx.multi {
+			ctx.aborted = true
+		}
+		conn.WriteError(newWrongNumOfArgsError(command).Error())
+		return
+	}
+	if ctx.multi {
+		ctx.queue = append(ctx.queue, queuedCmd{exec: exec, args: args})
+		conn.WriteString("QUEUED")
+		return
+	}
+
+	reply, err := s.execCmd(exec, args)
 	if err != nil {
 		conn.WriteError(err.Error())
 		return
 	}
-	conn.WriteAny(reply)
+	writeReply(conn, ctx, reply)
+}
+
+// execute a command, it can run concurrently with the other commands but not with EXEC.
+func (s *Server) execCmd(exec ExecCmdFunc, args []string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return exec(s.db, args)
+}
+
+// Shutdown stop accepting new connections and commands, and wait for the running commands until ctx is done.
+// Then the connections are closed, the listener and the db are closed after all the connections exit,
+// so the meta and config are always saved.
+// If ctx is done first, the error of ctx is returned, the connections are closed at once, and the running commands are
+// waited for at most closeTimeout more. If they still don`t finish, Shutdown returns and the db is closed after them in background.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stateMu.Lock()
+	s.closing = true
+	svr := s.server
+	s.stateMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("shutdown timeout, closing the connections with the running commands")
+	}
+
+	// the net.Conn can be closed in any goroutine, then the connection exits in its own goroutine.
+	// Closing the redcon.Conn here races with the running command.
+	for _, c := range s.listClients() {
+		_ = c.client.conn.NetConn().Close()
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		<-drained
+		s.conns.Wait()
+		// no connection is left, so closing the listener won`t close any connection.
+		if svr != nil {
+			if e := svr.Close(); e != nil {
+				log.Printf("close the listener err: %+v", e)
+			}
+		}
+		closed <- s.db.Close()
+	}()
+
+	select {
+	case e := <-closed:
+		if e != nil {
+			return e
+		}
+	case <-time.After(closeTimeout):
+		log.Printf("the running commands don`t finish in %v, the db will be closed after them", closeTimeout)
+		if err == nil {
+			err = ErrCloseTimeout
+		}
+	}
+	return err
+}
+
+// begin to execute a command, returns false if the server is shut down.
+func (s *Server) begin() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fastdb"
 	"fastdb/cmd"
 	"flag"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func init() {
@@ -19,6 +21,8 @@ func init() {
 
 var config = flag.String("config", "", "the config file for fastdb")
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
 	flag.Parse()
@@ -28,8 +32,14 @@ func main() {
 	if *config == "" {
 		log.Println("no config set, using the default config.")
 		cfg = fastdb.DefaultConfig()
+	} else {
+		c, err := fastdb.LoadConfigFile(*config)
+		if err != nil {
+			log.Printf("load config err: %+v\n", err)
+			return
+		}
+		cfg = c
 	}
-	//fmt.Println(cfg)
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, os.Kill, syscall.SIGHUP,
@@ -43,8 +53,14 @@ func main() {
 
 	go server.Listen(cfg.Addr)
 
-	// server.ln.Close()
 	<-sig
-	log.Println("rosedb is ready to exit, bye...")
+	log.Println("rosedb is shutting down...")
 
+	// the running commands are waited for a while, then the connections and the db are closed.
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("shutdown rosedb server err: %+v\n", err)
+	}
+	log.Println("rosedb is ready to exit, bye...")
 }
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fastdb"
+	"fastdb/storage"
+	"math"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// start a server listening on a random port.
+func startTestServer(t *testing.T) (*Server, string, fastdb.Config) {
+	cfg := fastdb.DefaultConfig()
+	cfg.DirPath = t.TempDir()
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	go s.Listen(addr)
+
+	for i := 0; i < 100; i++ {
+		var conn net.Conn
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			_ = conn.Close()
+			return s, addr, cfg
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(err)
+	return nil, "", cfg
+}
+
+// send a command which is still running after the deadline of shutdown, returns after it starts.
+func runSlowCmd(t *testing.T, addr string, d time.Duration) net.Conn {
+	started := make(chan struct{})
+	addExecCommand("slowset", func(db *fastdb.FastDB, args []string) (interface{}, error) {
+		close(started)
+		time.Sleep(d)
+		return okResult, db.Set([]byte(args[0]), []byte(args[1]))
+	}, 3)
+	t.Cleanup(func() {
+		delete(ExecCmd, "slowset")
+		delete(cmdArity, "slowset")
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_, _ = conn.Write([]byte("*3\r\n$7\r\nslowset\r\n$5\r\nafter\r\n$3\r\nval\r\n"))
+	<-started
+	return conn
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	s, addr, cfg := startTestServer(t)
+	assert.Nil(t, s.db.Set([]byte("before"), []byte("val")))
+	conn := runSlowCmd(t, addr, 300*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+
+	// the connection is closed without reply.
+	_, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NotNil(t, err)
+
+	// the config and meta are saved after the running command.
+	info, err := os.Stat(cfg.DirPath + "/DB.CFG")
+	assert.Nil(t, err)
+	assert.NotZero(t, info.Size())
+	meta := storage.LoadMeta(cfg.DirPath + "/DB.META")
+	assert.NotZero(t, meta.ActiveWriteOff[fastdb.String])
+
+	db, err := fastdb.Reopen(cfg.DirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, k := range []string{"before", "after"} {
+		val, err := db.Get([]byte(k))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("val"), val)
+	}
+}
+
+func TestServer_ShutdownCloseTimeout(t *testing.T) {
+	old := closeTimeout
+	closeTimeout = 50 * time.Millisecond
+	defer func() { closeTimeout = old }()
+
+	s, addr, cfg := startTestServer(t)
+	runSlowCmd(t, addr, 500*time.Millisecond)
+
+	// Shutdown returns before the running command finishes.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+	assert.True(t, time.Since(start) < 400*time.Millisecond)
+
+	// the db is closed after the command in background.
+	_, err := os.Stat(cfg.DirPath + "/DB.CFG")
+	assert.True(t, os.IsNotExist(err))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(cfg.DirPath + "/DB.CFG")
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestServer_MultiCheckCommand(t *testing.T) {
+	s, addr, _ := startTestServer(t)
+	defer s.Shutdown(context.Background())
+
+	conn, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, bad := range [][]interface{}{{"set", "k"}, {"notexist", "k"}} {
+		_, err = conn.Do("multi")
+		assert.Nil(t, err)
+		reply, err := conn.Do("set", "k", "v")
+		assert.Nil(t, err)
+		assert.Equal(t, "QUEUED", reply)
+
+		// the error is replied when queueing, and EXEC is aborted.
+		_, err = conn.Do(bad[0].(string), bad[1:]...)
+		assert.NotNil(t, err)
+		_, err = conn.Do("exec")
+		assert.Contains(t, err.Error(), "EXECABORT")
+
+		_, err = redis.Bytes(conn.Do("get", "k"))
+		assert.Equal(t, redis.ErrNil, err)
+	}
+}
+
+func TestServer_MultiConnCmd(t *testing.T) {
+	s, addr, _ := startTestServer(t)
+	defer s.Shutdown(context.Background())
+
+	conn, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("multi")
+	assert.Nil(t, err)
+	for _, cmd := range [][]interface{}{{"ping"}, {"ping", "hi"}, {"client", "setname", "conn1"}, {"set", "k", "v"}} {
+		reply, err := conn.Do(cmd[0].(string), cmd[1:]...)
+		assert.Nil(t, err)
+		assert.Equal(t, "QUEUED", reply)
+	}
+	// HELLO is refused, but the transaction is not discarded.
+	_, err = conn.Do("hello", "3")
+	assert.NotNil(t, err)
+
+	replies, err := redis.Values(conn.Do("exec"))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"PONG", []byte("hi"), "OK", "OK"}, replies)
+
+	name, err := redis.String(conn.Do("client", "getname"))
+	assert.Nil(t, err)
+	assert.Equal(t, "conn1", name)
+}
+
+func TestAppendReply(t *testing.T) {
+	tests := []struct {
+		reply interface{}
+		resp2 string
+		resp3 string
+	}{
+		{nil, "$-1\r\n", "_\r\n"},
+		{1.5, "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{math.Inf(-1), "$4\r\n-Inf\r\n", ",-inf\r\n"},
+		{mapResult{[]byte("f1"), []byte("v1")}, "*2\r\n$2\r\nf1\r\n$2\r\nv1\r\n", "%1\r\n$2\r\nf1\r\n$2\r\nv1\r\n"},
+		{[]interface{}{"m1", 2.0}, "*2\r\n$2\r\nm1\r\n$1\r\n2\r\n", "*2\r\n$2\r\nm1\r\n,2\r\n"},
+		{okResult, "+OK\r\n", "+OK\r\n"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.resp2, string(appendReply(nil, 2, tt.reply)))
+		assert.Equal(t, tt.resp3, string(appendReply(nil, 3, tt.reply)))
+	}
+}
+
+func TestServer_ClientKill(t *testing.T) {
+	s, addr, _ := startTestServer(t)
+	defer s.Shutdown(context.Background())
+
+	conn1, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	conn2, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	id, err := redis.Int64(conn2.Do("client", "id"))
+	assert.Nil(t, err)
+	killed, err := redis.Int(conn1.Do("client", "kill", "id", id))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, killed)
+
+	_, err = conn2.Do("ping")
+	assert.NotNil(t, err)
+	reply, err := conn1.Do("ping")
+	assert.Nil(t, err)
+	assert.Equal(t, "PONG", reply)
+}
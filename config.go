@@ -1,6 +1,18 @@
 package fastdb
 
-import "fastdb/storage"
+import (
+	"errors"
+	"fastdb/storage"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrInvalidConfig the config is invalid
+var ErrInvalidConfig = errors.New("rosedb: invalid config")
 
 // DataIndexMode the data index mode.
 type DataIndexMode int
@@ -33,15 +45,25 @@ const (
 	// DefaultMaxValueSize default max value size: 1mb.
 	DefaultMaxValueSize = uint32(1 * 1024 * 1024)
 
-	// DefaultReclaimThreshold default disk reclaim threshold: at least 4 archived db files.
+	// DefaultReclaimThreshold default disk reclaim threshold: more than 4 archived db files.
 	DefaultReclaimThreshold = 4
 
 	// DefaultSingleReclaimThreshold single reclaimable space: at least 4MB space of a db file.
 	// when a single db file`s reclaimable space reached the threshold, it can be reclaimed automatically.
 	// Only support String type now.
 	DefaultSingleReclaimThreshold = 4 * 1024 * 1024
+
+	// DefaultExpireCycleInterval default interval of deleting the expired keys in background: 100ms.
+	DefaultExpireCycleInterval = 100 * time.Millisecond
+
+	// DefaultExpireCycleBudget default max time spent in each expiration cycle: 25ms.
+	DefaultExpireCycleBudget = 25 * time.Millisecond
 )
 
+// the max size of the separators and integers in the extra of an entry, such as the list index and the zset score.
+// The rest of the extra is a hash field, a list pivot or a set key, which is no larger than the max value or key size.
+const maxExtraOverhead = 64
+
 // Config the config options of rosedb.
 type Config struct {
 	Addr                   string               `json:"addr" toml:"addr"`             // server address
@@ -51,9 +73,22 @@ type Config struct {
 	IdxMode                DataIndexMode        `json:"idx_mode" toml:"idx_mode"`     // data index mode
 	MaxKeySize             uint32               `json:"max_key_size" toml:"max_key_size"`
 	MaxValueSize           uint32               `json:"max_value_size" toml:"max_value_size"`
-	Sync                   bool                 `json:"sync" toml:"sync"`                           // sync to disk if necessary
-	ReclaimThreshold       int                  `json:"reclaim_threshold" toml:"reclaim_threshold"` // threshold to reclaim disk
-	SingleReclaimThreshold int64                `json:"single_reclaim_threshold"`                   // single reclaim threshold
+	Sync                   bool                 `json:"sync" toml:"sync"`                                         // sync to disk if necessary
+	ReclaimThreshold       int                  `json:"reclaim_threshold" toml:"reclaim_threshold"`               // threshold to reclaim disk
+	SingleReclaimThreshold int64                `json:"single_reclaim_threshold" toml:"single_reclaim_threshold"` // single reclaim threshold
+	SingleReclaimInterval  time.Duration        `json:"single_reclaim_interval" toml:"single_reclaim_interval"`   // interval of the single reclaim in background, 0 means never
+	ExpireCycleInterval    time.Duration        `json:"expire_cycle_interval" toml:"expire_cycle_interval"`       // interval of deleting the expired keys in background, 0 means never
+	ExpireCycleBudget      time.Duration        `json:"expire_cycle_budget" toml:"expire_cycle_budget"`           // max time spent in each expiration cycle
+}
+
+// the durations are written as strings in the toml file, such as "100ms" or "1h".
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalText(text []byte) (err error) {
+	d.Duration, err = time.ParseDuration(string(text))
+	return
 }
 
 // DefaultConfig get the default config.
@@ -69,5 +104,91 @@ func DefaultConfig() Config {
 		Sync:                   true,
 		ReclaimThreshold:       DefaultReclaimThreshold,
 		SingleReclaimThreshold: DefaultSingleReclaimThreshold,
+		ExpireCycleInterval:    DefaultExpireCycleInterval,
+		ExpireCycleBudget:      DefaultExpireCycleBudget,
+	}
+}
+
+// LoadConfigFile load the config from a toml file, the options not in the file keep their default values.
+// The config is validated after loading.
+func LoadConfigFile(path string) (config Config, err error) {
+	config = DefaultConfig()
+
+	var options map[string]toml.Primitive
+	md, err := toml.DecodeFile(path, &options)
+	if err != nil {
+		return config, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+
+	// find the fields of the options by the toml tags, so the wrong option can be reported.
+	fields := make(map[string]reflect.Value)
+	v := reflect.ValueOf(&config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fields[v.Type().Field(i).Tag.Get("toml")] = v.Field(i)
+	}
+
+	var keys []string
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			return config, fmt.Errorf("%w: %s: unknown option %s", ErrInvalidConfig, path, key)
+		}
+
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			var d duration
+			err = md.PrimitiveDecode(options[key], &d)
+			field.SetInt(int64(d.Duration))
+		} else {
+			err = md.PrimitiveDecode(options[key], field.Addr().Interface())
+		}
+		if err != nil {
+			return config, fmt.Errorf("%w: %s: %s: %v", ErrInvalidConfig, path, key, err)
+		}
+	}
+
+	err = config.Validate()
+	return
+}
+
+// the size of the largest entry, such as a hash field and its value.
+func (c Config) maxEntrySize() int64 {
+	maxExtraSize := int64(c.MaxValueSize)
+	if c.MaxKeySize > c.MaxValueSize {
+		maxExtraSize = int64(c.MaxKeySize)
+	}
+	return storage.EntryHeaderSize + int64(c.MaxKeySize) + int64(c.MaxValueSize) + maxExtraSize + maxExtraOverhead
+}
+
+// Validate check whether the options of the config are valid.
+func (c Config) Validate() error {
+	switch {
+	case c.DirPath == "":
+		return fmt.Errorf("%w: dir_path is empty", ErrInvalidConfig)
+	case c.MaxKeySize == 0:
+		return fmt.Errorf("%w: max_key_size must be positive", ErrInvalidConfig)
+	case c.MaxValueSize == 0:
+		return fmt.Errorf("%w: max_value_size must be positive", ErrInvalidConfig)
+	case c.BlockSize < storage.FileHeaderSize+c.maxEntrySize():
+		return fmt.Errorf("%w: block_size %d must be at least %d to hold the file header and the largest entry",
+			ErrInvalidConfig, c.BlockSize, storage.FileHeaderSize+c.maxEntrySize())
+	case c.RwMethod != storage.FileIO && c.RwMethod != storage.MMap:
+		return fmt.Errorf("%w: rw_method %d must be %d (FileIO) or %d (MMap)", ErrInvalidConfig, c.RwMethod, storage.FileIO, storage.MMap)
+	case c.IdxMode != KeyValueMemMode && c.IdxMode != KeyOnlyMemMode:
+		return fmt.Errorf("%w: idx_mode %d must be %d (KeyValueMemMode) or %d (KeyOnlyMemMode)", ErrInvalidConfig, c.IdxMode, KeyValueMemMode, KeyOnlyMemMode)
+	case c.ReclaimThreshold <= 0:
+		return fmt.Errorf("%w: reclaim_threshold must be positive", ErrInvalidConfig)
+	case c.SingleReclaimThreshold <= 0:
+		return fmt.Errorf("%w: single_reclaim_threshold must be positive", ErrInvalidConfig)
+	case c.SingleReclaimInterval < 0:
+		return fmt.Errorf("%w: single_reclaim_interval must not be negative", ErrInvalidConfig)
+	case c.ExpireCycleInterval < 0:
+		return fmt.Errorf("%w: expire_cycle_interval must not be negative", ErrInvalidConfig)
+	case c.ExpireCycleInterval > 0 && c.ExpireCycleBudget <= 0:
+		return fmt.Errorf("%w: expire_cycle_budget must be positive", ErrInvalidConfig)
 	}
+	return nil
 }
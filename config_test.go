@@ -0,0 +1,91 @@
+package fastdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("shipped config", func(t *testing.T) {
+		config, err := LoadConfigFile("config.tom")
+		assert.Nil(t, err)
+		expected := DefaultConfig()
+		expected.Sync = false
+		assert.Equal(t, expected, config)
+	})
+
+	t.Run("merge with default", func(t *testing.T) {
+		path := writeConfigFile(t, `
+dir_path = "/tmp/fastdb_config"
+idx_mode = 1
+block_size = 4194304
+expire_cycle_interval = "1s"
+`)
+		config, err := LoadConfigFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "/tmp/fastdb_config", config.DirPath)
+		assert.Equal(t, KeyOnlyMemMode, config.IdxMode)
+		assert.Equal(t, int64(4194304), config.BlockSize)
+		assert.Equal(t, time.Second, config.ExpireCycleInterval)
+		assert.Equal(t, DefaultExpireCycleBudget, config.ExpireCycleBudget)
+		assert.Equal(t, DefaultAddr, config.Addr)
+		assert.True(t, config.Sync)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for content, msg := range map[string]string{
+			`block_size = 1024`:              "block_size 1024 must be at least",
+			`rw_method = 2`:                  "rw_method 2",
+			`idx_mode = 3`:                   "idx_mode 3",
+			`dir_path = ""`:                  "dir_path is empty",
+			`expire_cycle_budget = "0"`:      "expire_cycle_budget must be positive",
+			`expire_cycle_interval = "1day"`: "time: unknown unit",
+			`blocksize = 1024`:               "unknown option blocksize",
+			`sync = "yes"`:                   "sync: toml: cannot load TOML value of type string into a Go boolean",
+		} {
+			_, err := LoadConfigFile(writeConfigFile(t, content))
+			assert.True(t, errors.Is(err, ErrInvalidConfig), content)
+			assert.Contains(t, err.Error(), msg)
+		}
+
+		_, err := LoadConfigFile(filepath.Join(t.TempDir(), "not_exist.toml"))
+		assert.True(t, errors.Is(err, ErrInvalidConfig))
+	})
+
+	t.Run("block size", func(t *testing.T) {
+		// file header 20 + entry header 26 + key 10 + value 100 + hash field 100 + extra overhead 64.
+		for _, tt := range []struct {
+			blockSize int64
+			valid     bool
+		}{
+			{blockSize: 319, valid: false},
+			{blockSize: 320, valid: true},
+		} {
+			config := DefaultConfig()
+			config.MaxKeySize, config.MaxValueSize, config.BlockSize = 10, 100, tt.blockSize
+			err := config.Validate()
+			assert.Equal(t, tt.valid, err == nil, tt.blockSize)
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		config := DefaultConfig()
+		config.DirPath = t.TempDir()
+		config.BlockSize = 1024
+		_, err := Open(config)
+		assert.True(t, errors.Is(err, ErrInvalidConfig))
+	})
+}
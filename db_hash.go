@@ -2,18 +2,23 @@ package fastdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fastdb/ds/hash"
 	"fastdb/storage"
 	"sync"
+	"time"
 )
 
 type HashIdx struct {
 	mu      sync.RWMutex
 	indexes *hash.Hash
+
+	// the snapshots of the running transactions.
+	snapshots map[*hashSnapshot]struct{}
 }
 
 func newHashIdx() *HashIdx {
-	return &HashIdx{indexes: hash.New()}
+	return &HashIdx{indexes: hash.New(), snapshots: make(map[*hashSnapshot]struct{})}
 }
 
 func (db *FastDB) HGet(key, field []byte) []byte {
@@ -24,32 +29,313 @@ func (db *FastDB) HGet(key, field []byte) []byte {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
-	if db.checkExpired(key, Hash) {
+	if db.isExpired(key, Hash) {
 		return nil
 	}
 
-	return db.hashIndex.indexes.HGet(string(key), string(field))
+	val, _ := db.hashVal(db.hashIndex.indexes.HGet(string(key), string(field)))
+	return val
 }
 
 func (db *FastDB) HSet(key []byte, field []byte, value []byte) (res int, err error) {
-	if err = db.checkKeyValue(key, value); err != nil {
+	if err = db.checkKeyValue(key, value, field); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	// the expired hash is cleared, then a new hash is created.
+	db.checkExpired(key, Hash)
+
+	// If the existed value is the same as the set value, nothing will be written, but the watchers still see the key set.
+	if db.config.IdxMode == KeyValueMemMode {
+		oldVal := db.hashIndex.indexes.HGet(string(key), string(field))
+		if bytes.Compare(oldVal, value) == 0 {
+			db.oracle.record(Hash, key)
+			return
+		}
+	}
+
+	e := storage.NewEntry(key, value, field, Hash, HashHSet)
+	if err = db.store(e); err != nil {
 		return
 	}
 
-	// If the existed value is the same as the set value, nothing will be done.
-	oldVal := db.HGet(key, field)
-	if bytes.Compare(oldVal, value) == 0 {
+	idxVal := db.hashIdxVal(value, db.activeFileIds[Hash], db.activeFile[Hash].Offset-int64(e.Size()))
+	res = db.hashIndex.indexes.HSet(string(key), string(field), idxVal)
+	return
+}
+
+// HSetNx sets field in the hash stored at key to value, only if field does not yet exist.
+// If key does not exist, a new key holding a hash is created. If field already exists, this operation has no effect.
+func (db *FastDB) HSetNx(key, field, value []byte) (res int, err error) {
+	if err = db.checkKeyValue(key, value, field); err != nil {
 		return
 	}
 
 	db.hashIndex.mu.Lock()
 	defer db.hashIndex.mu.Unlock()
 
+	// the expired hash is cleared, then a new hash is created.
+	db.checkExpired(key, Hash)
+
+	if db.hashIndex.indexes.HExists(string(key), string(field)) == 1 {
+		return
+	}
+
 	e := storage.NewEntry(key, value, field, Hash, HashHSet)
 	if err = db.store(e); err != nil {
 		return
 	}
 
-	res = db.hashIndex.indexes.HSet(string(key), string(field), value)
+	idxVal := db.hashIdxVal(value, db.activeFileIds[Hash], db.activeFile[Hash].Offset-int64(e.Size()))
+	res = db.hashIndex.indexes.HSetNx(string(key), string(field), idxVal)
 	return
 }
+
+// HGetAll returns all fields and values of the hash stored at key.
+// In the returned value, every field name is followed by its value, so the length of the reply is twice the size of the hash.
+func (db *FastDB) HGetAll(key []byte) (res [][]byte, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return
+	}
+
+	res = db.hashIndex.indexes.HGetAll(string(key))
+	for i := 1; i < len(res); i += 2 {
+		if res[i], err = db.hashVal(res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// HDel removes the specified fields from the hash stored at key. Specified fields that do not exist within this hash are ignored.
+// If key does not exist, it is treated as an empty hash and this command returns 0.
+// It returns the number of fields that were removed from the hash.
+func (db *FastDB) HDel(key []byte, fields ...[]byte) (res int, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	if db.checkExpired(key, Hash) {
+		return
+	}
+
+	for _, f := range fields {
+		if db.hashIndex.indexes.HExists(string(key), string(f)) == 0 {
+			continue
+		}
+
+		e := storage.NewEntry(key, nil, f, Hash, HashHDel)
+		if err = db.store(e); err != nil {
+			return
+		}
+		res += db.hashIndex.indexes.HDel(string(key), string(f))
+	}
+	return
+}
+
+// HKeyExists returns if the hash key exists.
+func (db *FastDB) HKeyExists(key []byte) bool {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return false
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return false
+	}
+	return db.hashIndex.indexes.HKeyExists(string(key))
+}
+
+// HExists returns if field is an existing field in the hash stored at key.
+func (db *FastDB) HExists(key, field []byte) bool {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return false
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return false
+	}
+	return db.hashIndex.indexes.HExists(string(key), string(field)) == 1
+}
+
+// HLen returns the number of fields contained in the hash stored at key.
+func (db *FastDB) HLen(key []byte) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return 0
+	}
+	return db.hashIndex.indexes.HLen(string(key))
+}
+
+// HKeys returns all field names in the hash stored at key.
+func (db *FastDB) HKeys(key []byte) (val []string) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return
+	}
+	return db.hashIndex.indexes.HKeys(string(key))
+}
+
+// HVals returns all values in the hash stored at key.
+func (db *FastDB) HVals(key []byte) (val [][]byte, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return
+	}
+
+	val = db.hashIndex.indexes.HVals(string(key))
+	for i := range val {
+		if val[i], err = db.hashVal(val[i]); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// HClear clear the key in hash.
+func (db *FastDB) HClear(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	if db.checkExpired(key, Hash) || !db.hashIndex.indexes.HKeyExists(string(key)) {
+		return
+	}
+
+	e := storage.NewEntryNoExtra(key, nil, Hash, HashHClear)
+	if err = db.store(e); err != nil {
+		return
+	}
+	db.hashIndex.indexes.HClear(string(key))
+	delete(db.expires[Hash], string(key))
+	return
+}
+
+// HExpire set the expiration time of the hash key, in seconds.
+func (db *FastDB) HExpire(key []byte, duration int64) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if duration <= 0 {
+		return ErrInvalidTTL
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	if !db.hashIndex.indexes.HKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, Hash) {
+		return ErrKeyExpired
+	}
+	return db.expire(key, time.Now().Unix()+duration, Hash)
+}
+
+// HPersist remove the existing timeout on the hash key.
+func (db *FastDB) HPersist(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	if !db.hashIndex.indexes.HKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, Hash) {
+		return ErrKeyExpired
+	}
+	return db.persist(key, Hash)
+}
+
+// HTTL returns the remaining time to live of the hash key, in seconds.
+// It returns -1 if the key exists but has no associated expire, and -2 if the key does not exist.
+func (db *FastDB) HTTL(key []byte) (ttl int64) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return -2
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if !db.hashIndex.indexes.HKeyExists(string(key)) || db.isExpired(key, Hash) {
+		return -2
+	}
+	return db.ttl(key, Hash)
+}
+
+// the value saved in the hash indexes, it is the position of the entry in the db file in KeyOnlyMemMode.
+func (db *FastDB) hashIdxVal(value []byte, fileId uint32, offset int64) []byte {
+	if db.config.IdxMode == KeyValueMemMode {
+		return value
+	}
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint32(buf[0:4], fileId)
+	binary.BigEndian.PutUint64(buf[4:12], uint64(offset))
+	return buf
+}
+
+// decode the position of the entry saved in the hash indexes in KeyOnlyMemMode.
+func decodeHashPos(idxVal []byte) (fileId uint32, offset int64) {
+	return binary.BigEndian.Uint32(idxVal[0:4]), int64(binary.BigEndian.Uint64(idxVal[4:12]))
+}
+
+// get the value of a field from the hash indexes, the value is read from the db file in KeyOnlyMemMode.
+// The caller should hold the lock of hashIndex.
+func (db *FastDB) hashVal(idxVal []byte) ([]byte, error) {
+	if idxVal == nil || db.config.IdxMode == KeyValueMemMode {
+		return idxVal, nil
+	}
+
+	fileId, offset := decodeHashPos(idxVal)
+	df := db.getDBFile(Hash, fileId)
+	if df == nil {
+		return nil, ErrNilIndexer
+	}
+	e, err := df.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	return e.Meta.Value, nil
+}
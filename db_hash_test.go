@@ -1,60 +1,138 @@
 package fastdb
 
 import (
-	"log"
+	"fmt"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var key = "myhash"
 
 func TestFastDB_HSet(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
 
 	t.Run("test1", func(t *testing.T) {
-		db := InitDb()
-		defer db.Close()
-
-		db.HSet(nil, nil, nil)
-
-		_, _ = db.HSet([]byte(key), []byte("my_name"), []byte("roseduan"))
-
-		//val := db.HGet([]byte(key), []byte("my_name"))
-		//log.Println(string(val))
+		_, err := db.HSet(nil, nil, nil)
+		assert.Equal(t, ErrEmptyKey, err)
 
+		_, err = db.HSet([]byte(key), []byte("my_name"), []byte("roseduan"))
+		assert.Nil(t, err)
 	})
 
 	t.Run("reopen and set", func(t *testing.T) {
-		db := ReopenDb()
-		defer db.Close()
-		//_, _ = db.HSet([]byte(key), []byte("my_hobby"), []byte("coding better"))
-		//_, _ = db.HSet([]byte(key), []byte("my_lang"), []byte("Java and Go"))
+		db = CloseAndReopen(t, db)
+		_, err := db.HSet([]byte(key), []byte("my_hobby"), []byte("coding better"))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, db.HLen([]byte(key)))
 	})
-
-	//t.Run("multi data", func(t *testing.T) {
-	//	db := ReopenDb()
-	//	defer db.Close()
-	//
-	//	rand.Seed(time.Now().Unix())
-	//
-	//	fieldPrefix := "hash_field_"
-	//	valPrefix := "hash_data_"
-	//
-	//	var res int
-	//	for i := 0; i < 100000; i++ {
-	//		field := fieldPrefix + strconv.Itoa(rand.Intn(1000000))
-	//		val := valPrefix + strconv.Itoa(rand.Intn(1000000))
-	//
-	//		res, _ = db.HSet([]byte(key), []byte(field), []byte(val))
-	//	}
-	//	t.Log(res)
-	//})
+	_ = db.Close()
 }
 
 func TestFastDB_HGet(t *testing.T) {
-	db := ReopenDb()
+	db := InitTestDb(t, KeyOnlyMemMode)
+	_, _ = db.HSet([]byte(key), []byte("my_name"), []byte("roseduan"))
+
+	db = CloseAndReopen(t, db)
 	defer db.Close()
-	//_, _ = db.HSet([]byte(key), []byte("my_name"), []byte("roseduan"))
 
 	val := db.HGet([]byte(key), []byte("my_name"))
-	log.Println(string(val))
+	assert.Equal(t, []byte("roseduan"), val)
+}
+
+func TestFastDB_HashKeyOnlyMemMode(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+
+	for i := 0; i < 500; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i%5))
+		_, _ = db.HSet(key, []byte(fmt.Sprintf("field-%d", i%20)), []byte(fmt.Sprintf("val-%04d", i)))
+	}
+	wb := db.NewWriteBatch()
+	_ = wb.HSet([]byte("key-0"), []byte("batch"), []byte("batch-val"))
+	assert.Nil(t, wb.Commit())
+
+	// only the positions of the values are in memory.
+	assert.NotEqual(t, []byte("val-0499"), db.hashIndex.indexes.HGet("key-4", "field-19"))
+
+	check := func(db *FastDB) {
+		for i := 480; i < 500; i++ {
+			val := db.HGet([]byte(fmt.Sprintf("key-%d", i%5)), []byte(fmt.Sprintf("field-%d", i%20)))
+			assert.Equal(t, fmt.Sprintf("val-%04d", i), string(val))
+		}
+		assert.Equal(t, []byte("batch-val"), db.HGet([]byte("key-0"), []byte("batch")))
+		err := db.Txn(func(tx *Txn) error {
+			val, err := tx.HGet([]byte("key-0"), []byte("batch"))
+			assert.Equal(t, []byte("batch-val"), val)
+			return err
+		})
+		assert.Nil(t, err)
+	}
+	check(db)
+
+	// the values are moved to the new db files.
+	assert.Nil(t, db.Reclaim())
+	db = CheckAndReopen(t, db, check)
+	defer db.Close()
+}
+
+func TestFastDB_Hash_Reopen(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := InitTestDb(t, mode)
+
+		k := []byte("my_hash")
+		_, _ = db.HSet(k, []byte("a"), []byte("val-a"))
+		_, _ = db.HSet(k, []byte("b"), []byte("val-b"))
+		_, _ = db.HSet(k, []byte("c"), []byte("val-c"))
+
+		// the field is saved as the extra of the entry, it is no larger than the max value size.
+		_, err := db.HSet(k, make([]byte, db.config.MaxValueSize+1), []byte("val"))
+		assert.Equal(t, ErrValueTooLarge, err)
+
+		res, err := db.HSetNx(k, []byte("a"), []byte("new-a"))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, res)
+		res, _ = db.HSetNx(k, []byte("d"), []byte("val-d"))
+		assert.Equal(t, 1, res)
+
+		res, err = db.HDel(k, []byte("b"), []byte("not_exist"))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, res)
+
+		_, _ = db.HSet([]byte("cleared"), []byte("a"), []byte("val-a"))
+		assert.Nil(t, db.HClear([]byte("cleared")))
+
+		check := func(db *FastDB) {
+			assert.True(t, db.HKeyExists(k))
+			assert.False(t, db.HKeyExists([]byte("cleared")))
+			assert.True(t, db.HExists(k, []byte("a")))
+			assert.False(t, db.HExists(k, []byte("b")))
+			assert.Equal(t, 3, db.HLen(k))
+			assert.ElementsMatch(t, []string{"a", "c", "d"}, db.HKeys(k))
+
+			vals, err := db.HVals(k)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"val-a", "val-c", "val-d"}, sortedMembers(vals))
+
+			all, err := db.HGetAll(k)
+			assert.Nil(t, err)
+			assert.Equal(t, 6, len(all))
+			for i := 0; i < len(all); i += 2 {
+				assert.Equal(t, "val-"+string(all[i]), string(all[i+1]))
+			}
+		}
+		db = CheckAndReopen(t, db, check)
 
+		// the expired hash is treated as an empty one.
+		db.expires[Hash][string(k)] = time.Now().Unix() - 1
+		assert.False(t, db.HKeyExists(k))
+		assert.False(t, db.HExists(k, []byte("a")))
+		assert.Equal(t, 0, db.HLen(k))
+		assert.Nil(t, db.HKeys(k))
+		all, _ := db.HGetAll(k)
+		assert.Nil(t, all)
+		res, _ = db.HDel(k, []byte("a"))
+		assert.Equal(t, 0, res)
+		_ = db.Close()
+	}
 }
@@ -0,0 +1,387 @@
+package fastdb
+
+import (
+	"bytes"
+	"fastdb/ds/list"
+	"fastdb/storage"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ListIdx the list idx
+type ListIdx struct {
+	mu      sync.RWMutex
+	indexes *list.List
+}
+
+func newListIdx() *ListIdx {
+	return &ListIdx{indexes: list.New()}
+}
+
+// LPush insert all the specified values at the head of the list stored at key.
+// If key does not exist, it is created as empty list before performing the push operations.
+func (db *FastDB) LPush(key []byte, values ...[]byte) (res int, err error) {
+	return db.push(key, true, values...)
+}
+
+// RPush insert all the specified values at the tail of the list stored at key.
+// If key does not exist, it is created as empty list before performing the push operation.
+func (db *FastDB) RPush(key []byte, values ...[]byte) (res int, err error) {
+	return db.push(key, false, values...)
+}
+
+// LPop removes and returns the first elements of the list stored at key.
+func (db *FastDB) LPop(key []byte) ([]byte, error) {
+	return db.pop(key, true)
+}
+
+// RPop Removes and returns the last elements of the list stored at key.
+func (db *FastDB) RPop(key []byte) ([]byte, error) {
+	return db.pop(key, false)
+}
+
+// LIndex returns the element at index index in the list stored at key.
+// The index is zero-based, so 0 means the first element, 1 the second element and so on.
+// Negative indices can be used to designate elements starting at the tail of the list. Here, -1 means the last element, -2 means the penultimate and so forth.
+func (db *FastDB) LIndex(key []byte, idx int) []byte {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.isExpired(key, List) {
+		return nil
+	}
+	return db.listIndex.indexes.LIndex(string(key), idx)
+}
+
+// LRem removes the first count occurrences of elements equal to element from the list stored at key.
+// The count argument influences the operation in the following ways:
+// count > 0: Remove elements equal to element moving from head to tail.
+// count < 0: Remove elements equal to element moving from tail to head.
+// count = 0: Remove all elements equal to element.
+func (db *FastDB) LRem(key, value []byte, count int) (int, error) {
+	if err := db.checkKeyValue(key, value); err != nil {
+		return 0, err
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.checkExpired(key, List) {
+		return 0, ErrKeyExpired
+	}
+
+	// the entry is stored first, the list is changed only if it succeeds.
+	if !db.listIndex.indexes.LValExists(string(key), value) {
+		return 0, nil
+	}
+	c := strconv.Itoa(count)
+	e := storage.NewEntry(key, value, []byte(c), List, ListLRem)
+	if err := db.store(e); err != nil {
+		return 0, err
+	}
+	return db.listIndex.indexes.LRem(string(key), value, count), nil
+}
+
+// LInsert inserts element in the list stored at key either before or after the reference value pivot.
+// It returns the length of the list after the insert operation, or -1 when the value pivot was not found.
+func (db *FastDB) LInsert(key []byte, option list.InsertOption, pivot, val []byte) (count int, err error) {
+	if err = db.checkKeyValue(key, val); err != nil {
+		return
+	}
+
+	if bytes.Contains(pivot, []byte(ExtraSeparator)) {
+		return 0, ErrExtraContainsSeparator
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.checkExpired(key, List) {
+		return 0, ErrKeyExpired
+	}
+
+	if !db.listIndex.indexes.LValExists(string(key), pivot) {
+		return -1, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pivot)
+	buf.Write([]byte(ExtraSeparator))
+	opt := strconv.Itoa(int(option))
+	buf.Write([]byte(opt))
+
+	e := storage.NewEntry(key, val, buf.Bytes(), List, ListLInsert)
+	if err = db.store(e); err != nil {
+		return
+	}
+	count = db.listIndex.indexes.LInsert(string(key), option, pivot, val)
+	return
+}
+
+// LSet sets the list element at index to element.
+// returns whether is successful.
+func (db *FastDB) LSet(key []byte, idx int, val []byte) (ok bool, err error) {
+	if err = db.checkKeyValue(key, val); err != nil {
+		return
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.checkExpired(key, List) {
+		return false, ErrKeyExpired
+	}
+
+	if length := db.listIndex.indexes.LLen(string(key)); idx < -length || idx >= length {
+		return false, nil
+	}
+
+	i := strconv.Itoa(idx)
+	e := storage.NewEntry(key, val, []byte(i), List, ListLSet)
+	if err = db.store(e); err != nil {
+		return
+	}
+	ok = db.listIndex.indexes.LSet(string(key), idx, val)
+	return
+}
+
+// LTrim trim an existing list so that it will contain only the specified range of elements specified.
+// Both start and stop are zero-based indexes, where 0 is the first element of the list (the head), 1 the next element and so on.
+func (db *FastDB) LTrim(key []byte, start, end int) error {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return err
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.checkExpired(key, List) {
+		return ErrKeyExpired
+	}
+
+	// nothing is trimmed if the range covers the whole list.
+	length := db.listIndex.indexes.LLen(string(key))
+	if length == 0 || len(db.listIndex.indexes.LRange(string(key), start, end)) == length {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write([]byte(strconv.Itoa(start)))
+	buf.Write([]byte(ExtraSeparator))
+	buf.Write([]byte(strconv.Itoa(end)))
+
+	e := storage.NewEntry(key, nil, buf.Bytes(), List, ListLTrim)
+	if err := db.store(e); err != nil {
+		return err
+	}
+	db.listIndex.indexes.LTrim(string(key), start, end)
+	return nil
+}
+
+// LRange returns the specified elements of the list stored at key.
+// The offsets start and stop are zero-based indexes, with 0 being the first element of the list (the head of the list), 1 being the next element and so on.
+// These offsets can also be negative numbers indicating offsets starting at the end of the list.
+// For example, -1 is the last element of the list, -2 the penultimate, and so on.
+func (db *FastDB) LRange(key []byte, start, end int) ([][]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.isExpired(key, List) {
+		return nil, ErrKeyExpired
+	}
+	return db.listIndex.indexes.LRange(string(key), start, end), nil
+}
+
+// LLen returns the length of the list stored at key.
+// If key does not exist, it is interpreted as an empty list and 0 is returned.
+func (db *FastDB) LLen(key []byte) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.isExpired(key, List) {
+		return 0
+	}
+	return db.listIndex.indexes.LLen(string(key))
+}
+
+// LKeyExists check if the key of a List exists.
+func (db *FastDB) LKeyExists(key []byte) (ok bool) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.isExpired(key, List) {
+		return false
+	}
+	return db.listIndex.indexes.LKeyExists(string(key))
+}
+
+// LValExists check if the val exists in a specified List stored at key.
+func (db *FastDB) LValExists(key []byte, val []byte) (ok bool) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.isExpired(key, List) {
+		return false
+	}
+	return db.listIndex.indexes.LValExists(string(key), val)
+}
+
+// LClear clear a specified key for List.
+func (db *FastDB) LClear(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.checkExpired(key, List) || !db.listIndex.indexes.LKeyExists(string(key)) {
+		return
+	}
+
+	e := storage.NewEntryNoExtra(key, nil, List, ListLClear)
+	if err = db.store(e); err != nil {
+		return
+	}
+	db.listIndex.indexes.LClear(string(key))
+	delete(db.expires[List], string(key))
+	return
+}
+
+// LExpire set the expiration time of the list key, in seconds.
+func (db *FastDB) LExpire(key []byte, duration int64) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if duration <= 0 {
+		return ErrInvalidTTL
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if !db.listIndex.indexes.LKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, List) {
+		return ErrKeyExpired
+	}
+	return db.expire(key, time.Now().Unix()+duration, List)
+}
+
+// LPersist remove the existing timeout on the list key.
+func (db *FastDB) LPersist(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if !db.listIndex.indexes.LKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, List) {
+		return ErrKeyExpired
+	}
+	return db.persist(key, List)
+}
+
+// LTTL returns the remaining time to live of the list key, in seconds.
+// It returns -1 if the key exists but has no associated expire, and -2 if the key does not exist.
+func (db *FastDB) LTTL(key []byte) (ttl int64) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return -2
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if !db.listIndex.indexes.LKeyExists(string(key)) || db.isExpired(key, List) {
+		return -2
+	}
+	return db.ttl(key, List)
+}
+
+func (db *FastDB) push(key []byte, isLeft bool, values ...[]byte) (res int, err error) {
+	if err = db.checkKeyValue(key, values...); err != nil {
+		return
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	// the expired list is cleared, then a new list is created.
+	db.checkExpired(key, List)
+
+	for _, val := range values {
+		var e *storage.Entry
+		if isLeft {
+			e = storage.NewEntryNoExtra(key, val, List, ListLPush)
+		} else {
+			e = storage.NewEntryNoExtra(key, val, List, ListRPush)
+		}
+		if err = db.store(e); err != nil {
+			return
+		}
+
+		if isLeft {
+			res = db.listIndex.indexes.LPush(string(key), val)
+		} else {
+			res = db.listIndex.indexes.RPush(string(key), val)
+		}
+	}
+	return
+}
+
+func (db *FastDB) pop(key []byte, isLeft bool) ([]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.checkExpired(key, List) {
+		return nil, ErrKeyExpired
+	}
+
+	var e *storage.Entry
+	if isLeft {
+		e = storage.NewEntryNoExtra(key, db.listIndex.indexes.LIndex(string(key), 0), List, ListLPop)
+	} else {
+		e = storage.NewEntryNoExtra(key, db.listIndex.indexes.LIndex(string(key), -1), List, ListRPop)
+	}
+	if e.Meta.Value == nil {
+		return nil, nil
+	}
+	if err := db.store(e); err != nil {
+		return nil, err
+	}
+
+	if isLeft {
+		return db.listIndex.indexes.LPop(string(key)), nil
+	}
+	return db.listIndex.indexes.RPop(string(key)), nil
+}
@@ -0,0 +1,122 @@
+package fastdb
+
+import (
+	"fastdb/ds/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastDB_LPush(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_, err := db.LPush(nil, []byte("a"))
+	assert.Equal(t, ErrEmptyKey, err)
+
+	res, err := db.LPush([]byte("mylist"), []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res)
+
+	res, err = db.RPush([]byte("mylist"), []byte("d"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, res)
+
+	vals, _ := db.LRange([]byte("mylist"), 0, -1)
+	assert.Equal(t, [][]byte{[]byte("c"), []byte("b"), []byte("a"), []byte("d")}, vals)
+}
+
+func TestFastDB_LPop(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	val, err := db.LPop([]byte("not_exist"))
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	_, _ = db.RPush([]byte("mylist"), []byte("a"), []byte("b"), []byte("c"))
+
+	val, _ = db.LPop([]byte("mylist"))
+	assert.Equal(t, []byte("a"), val)
+	val, _ = db.RPop([]byte("mylist"))
+	assert.Equal(t, []byte("c"), val)
+	assert.Equal(t, 1, db.LLen([]byte("mylist")))
+}
+
+func TestFastDB_LInsert(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_, _ = db.RPush([]byte("mylist"), []byte("a"), []byte("c"))
+
+	count, err := db.LInsert([]byte("mylist"), list.Before, []byte("c"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	count, _ = db.LInsert([]byte("mylist"), list.After, []byte("not_exist"), []byte("b"))
+	assert.Equal(t, -1, count)
+
+	_, err = db.LInsert([]byte("mylist"), list.After, []byte("a"+ExtraSeparator), []byte("b"))
+	assert.Equal(t, ErrExtraContainsSeparator, err)
+}
+
+func TestFastDB_List_Reopen(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+
+	key := []byte("mylist")
+	_, _ = db.RPush(key, []byte("a"), []byte("b"), []byte("c"), []byte("b"), []byte("e"), []byte("f"))
+	_, _ = db.LPush(key, []byte("z"))
+	_, _ = db.RPop(key)
+	_, _ = db.LPop(key)
+	_, _ = db.LRem(key, []byte("b"), 0)
+	_, _ = db.LInsert(key, list.After, []byte("a"), []byte("x"))
+	_, _ = db.LSet(key, -1, []byte("y"))
+	_ = db.LTrim(key, 0, 2)
+	_, _ = db.RPush([]byte("cleared"), []byte("a"))
+	_ = db.LClear([]byte("cleared"))
+
+	check := func(db *FastDB) {
+		vals, _ := db.LRange(key, 0, -1)
+		assert.Equal(t, [][]byte{[]byte("a"), []byte("x"), []byte("c")}, vals)
+		assert.Equal(t, []byte("x"), db.LIndex(key, 1))
+		assert.True(t, db.LValExists(key, []byte("c")))
+		assert.False(t, db.LValExists(key, []byte("b")))
+		assert.False(t, db.LKeyExists([]byte("cleared")))
+	}
+	db = CheckAndReopen(t, db, check)
+	defer db.Close()
+}
+
+func TestFastDB_List_NoOpNotStored(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	key := []byte("mylist")
+	_, _ = db.RPush(key, []byte("a"), []byte("b"))
+	offset := db.activeFile[List].Offset
+
+	// the entry is stored only if the list is changed.
+	res, err := db.LRem(key, []byte("not_exist"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, res)
+	count, err := db.LInsert(key, list.Before, []byte("not_exist"), []byte("x"))
+	assert.Nil(t, err)
+	assert.Equal(t, -1, count)
+	ok, err := db.LSet(key, 2, []byte("x"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, db.LTrim(key, -5, 5))
+	val, err := db.LPop([]byte("not_exist"))
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+	assert.Equal(t, offset, db.activeFile[List].Offset)
+
+	ok, err = db.LSet(key, -2, []byte("x"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	val, _ = db.RPop(key)
+	assert.Equal(t, []byte("b"), val)
+	assert.Nil(t, db.LTrim(key, 1, 1))
+	assert.Equal(t, 0, db.LLen(key))
+	assert.True(t, db.activeFile[List].Offset > offset)
+}
@@ -0,0 +1,328 @@
+package fastdb
+
+import (
+	"fastdb/ds/set"
+	"fastdb/storage"
+	"sync"
+	"time"
+)
+
+// SetIdx the set idx
+type SetIdx struct {
+	mu      sync.RWMutex
+	indexes *set.Set
+}
+
+func newSetIdx() *SetIdx {
+	return &SetIdx{indexes: set.New()}
+}
+
+// SAdd add the specified members to the set stored at key.
+// Specified members that are already a member of this set are ignored.
+// If key does not exist, a new set is created before adding the specified members.
+// It returns the number of members that were added to the set.
+func (db *FastDB) SAdd(key []byte, members ...[]byte) (res int, err error) {
+	if err = db.checkKeyValue(key, members...); err != nil {
+		return
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	// the expired key is cleared, then a new one is created.
+	db.checkExpired(key, Set)
+
+	for _, m := range members {
+		if exist := db.setIndex.indexes.SIsMember(string(key), m); exist {
+			continue
+		}
+
+		e := storage.NewEntryNoExtra(key, m, Set, SetSAdd)
+		if err = db.store(e); err != nil {
+			return
+		}
+		db.setIndex.indexes.SAdd(string(key), m)
+		res++
+	}
+	return
+}
+
+// SPop removes and returns one or more random members from the set value store at key.
+func (db *FastDB) SPop(key []byte, count int) (values [][]byte, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	if db.checkExpired(key, Set) {
+		return nil, ErrKeyExpired
+	}
+
+	values = db.setIndex.indexes.SPop(string(key), count)
+	for _, v := range values {
+		e := storage.NewEntryNoExtra(key, v, Set, SetSRem)
+		if err = db.store(e); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// SIsMember returns if member is a member of the set stored at key.
+func (db *FastDB) SIsMember(key, member []byte) bool {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return false
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return false
+	}
+
+	return db.setIndex.indexes.SIsMember(string(key), member)
+}
+
+// SRandMember returns a random element from the set value stored at key.
+// count > 0: if count less than set`s card, returns an array containing count different elements. if count greater than set`s card, the entire set will be returned.
+// count < 0: the command is allowed to return the same element multiple times, and in this case, the number of returned elements is the absolute value of the specified count.
+func (db *FastDB) SRandMember(key []byte, count int) [][]byte {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return nil
+	}
+
+	return db.setIndex.indexes.SRandMember(string(key), count)
+}
+
+// SRem remove the specified members from the set stored at key.
+// Specified members that are not a member of this set are ignored.
+// If key does not exist, it is treated as an empty set and this command returns 0.
+func (db *FastDB) SRem(key []byte, members ...[]byte) (res int, err error) {
+	if err = db.checkKeyValue(key, members...); err != nil {
+		return
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	if db.checkExpired(key, Set) {
+		return 0, ErrKeyExpired
+	}
+
+	for _, m := range members {
+		if ok := db.setIndex.indexes.SRem(string(key), m); ok {
+			e := storage.NewEntryNoExtra(key, m, Set, SetSRem)
+			if err = db.store(e); err != nil {
+				return
+			}
+			res++
+		}
+	}
+	return
+}
+
+// SMove move member from the set at source to the set at destination.
+// If the source set does not exist or does not contain the specified element, no operation is performed and returns false.
+func (db *FastDB) SMove(src, dst, member []byte) (ok bool, err error) {
+	if err = db.checkKeyValue(src, member); err != nil {
+		return
+	}
+	if err = db.checkKeyValue(dst, nil); err != nil {
+		return
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	if db.checkExpired(src, Set) {
+		return false, ErrKeyExpired
+	}
+	// the expired destination is cleared, then the member is moved to a new set.
+	db.checkExpired(dst, Set)
+
+	if ok = db.setIndex.indexes.SMove(string(src), string(dst), member); ok {
+		// the destination key is saved as the extra info of the entry.
+		e := storage.NewEntry(src, member, dst, Set, SetSMove)
+		if err = db.store(e); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// SCard returns the set cardinality (number of elements) of the set stored at key.
+func (db *FastDB) SCard(key []byte) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return 0
+	}
+
+	return db.setIndex.indexes.SCard(string(key))
+}
+
+// SMembers returns all the members of the set value stored at key.
+func (db *FastDB) SMembers(key []byte) (val [][]byte) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return
+	}
+
+	return db.setIndex.indexes.SMembers(string(key))
+}
+
+// SUnion returns the members of the set resulting from the union of all the given sets.
+func (db *FastDB) SUnion(keys ...[]byte) (val [][]byte) {
+	if len(keys) == 0 {
+		return
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	var validKeys []string
+	for _, k := range keys {
+		if db.isExpired(k, Set) {
+			continue
+		}
+		validKeys = append(validKeys, string(k))
+	}
+	return db.setIndex.indexes.SUnion(validKeys...)
+}
+
+// SDiff returns the members of the set resulting from the difference between the first set and all the successive sets.
+func (db *FastDB) SDiff(keys ...[]byte) (val [][]byte) {
+	if len(keys) == 0 {
+		return
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	// the expired key is treated as an empty set.
+	if db.isExpired(keys[0], Set) {
+		return
+	}
+	var validKeys []string
+	for _, k := range keys {
+		if db.isExpired(k, Set) {
+			continue
+		}
+		validKeys = append(validKeys, string(k))
+	}
+	return db.setIndex.indexes.SDiff(validKeys...)
+}
+
+// SKeyExists returns if the key exists.
+func (db *FastDB) SKeyExists(key []byte) (ok bool) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return
+	}
+
+	return db.setIndex.indexes.SKeyExists(string(key))
+}
+
+// SClear clear the specified key in set.
+func (db *FastDB) SClear(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	if db.checkExpired(key, Set) || !db.setIndex.indexes.SKeyExists(string(key)) {
+		return
+	}
+
+	e := storage.NewEntryNoExtra(key, nil, Set, SetSClear)
+	if err = db.store(e); err != nil {
+		return
+	}
+	db.setIndex.indexes.SClear(string(key))
+	delete(db.expires[Set], string(key))
+	return
+}
+
+// SExpire set the expiration time of the set key, in seconds.
+func (db *FastDB) SExpire(key []byte, duration int64) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if duration <= 0 {
+		return ErrInvalidTTL
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	if !db.setIndex.indexes.SKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, Set) {
+		return ErrKeyExpired
+	}
+	return db.expire(key, time.Now().Unix()+duration, Set)
+}
+
+// SPersist remove the existing timeout on the set key.
+func (db *FastDB) SPersist(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	if !db.setIndex.indexes.SKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, Set) {
+		return ErrKeyExpired
+	}
+	return db.persist(key, Set)
+}
+
+// STTL returns the remaining time to live of the set key, in seconds.
+// It returns -1 if the key exists but has no associated expire, and -2 if the key does not exist.
+func (db *FastDB) STTL(key []byte) (ttl int64) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return -2
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if !db.setIndex.indexes.SKeyExists(string(key)) || db.isExpired(key, Set) {
+		return -2
+	}
+	return db.ttl(key, Set)
+}
@@ -0,0 +1,86 @@
+package fastdb
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sortedMembers(members [][]byte) (res []string) {
+	for _, m := range members {
+		res = append(res, string(m))
+	}
+	sort.Strings(res)
+	return
+}
+
+func TestFastDB_SAdd(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_, err := db.SAdd(nil, []byte("a"))
+	assert.Equal(t, ErrEmptyKey, err)
+
+	res, err := db.SAdd([]byte("myset"), []byte("a"), []byte("b"), []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res)
+
+	res, _ = db.SAdd([]byte("myset"), []byte("b"), []byte("c"))
+	assert.Equal(t, 1, res)
+	assert.Equal(t, 3, db.SCard([]byte("myset")))
+	assert.True(t, db.SIsMember([]byte("myset"), []byte("c")))
+}
+
+func TestFastDB_SPop(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_, _ = db.SAdd([]byte("myset"), []byte("a"), []byte("b"), []byte("c"))
+
+	values, err := db.SPop([]byte("myset"), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(values))
+	assert.Equal(t, 1, db.SCard([]byte("myset")))
+}
+
+func TestFastDB_SUnionAndSDiff(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_, _ = db.SAdd([]byte("set1"), []byte("a"), []byte("b"), []byte("c"))
+	_, _ = db.SAdd([]byte("set2"), []byte("c"), []byte("d"))
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, sortedMembers(db.SUnion([]byte("set1"), []byte("set2"))))
+	assert.Equal(t, []string{"a", "b"}, sortedMembers(db.SDiff([]byte("set1"), []byte("set2"))))
+}
+
+func TestFastDB_Set_Reopen(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+
+	_, _ = db.SAdd([]byte("src"), []byte("a"), []byte("b"), []byte("c"), []byte("d"))
+	_, _ = db.SAdd([]byte("dst"), []byte("x"))
+	_, _ = db.SRem([]byte("src"), []byte("b"), []byte("not_exist"))
+
+	ok, err := db.SMove([]byte("src"), []byte("dst"), []byte("c"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = db.SMove([]byte("src"), []byte("dst"), []byte("not_exist"))
+	assert.False(t, ok)
+
+	popped, _ := db.SPop([]byte("src"), 1)
+	_, _ = db.SAdd([]byte("cleared"), []byte("a"))
+	_ = db.SClear([]byte("cleared"))
+
+	src, dst := sortedMembers(db.SMembers([]byte("src"))), sortedMembers(db.SMembers([]byte("dst")))
+	assert.Equal(t, []string{"c", "x"}, dst)
+	assert.Equal(t, 1, len(src))
+	assert.NotContains(t, src, string(popped[0]))
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+
+	assert.Equal(t, src, sortedMembers(db.SMembers([]byte("src"))))
+	assert.Equal(t, dst, sortedMembers(db.SMembers([]byte("dst"))))
+	assert.False(t, db.SKeyExists([]byte("cleared")))
+}
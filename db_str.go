@@ -5,15 +5,33 @@ import (
 	"fastdb/index"
 	"fastdb/storage"
 	"sync"
+	"time"
 )
 
 type StrIndex struct {
 	mu      sync.RWMutex
 	idxList *index.SkipList
+	keys    *index.KeySet // the keys ordered by hash, for scanning.
+
+	// the snapshots of the running iterators.
+	snapshots map[*strSnapshot]struct{}
 }
 
 func NewStrIdx() *StrIndex {
-	return &StrIndex{idxList: index.NewSkipList()}
+	return &StrIndex{idxList: index.NewSkipList(), keys: index.NewKeySet(), snapshots: make(map[*strSnapshot]struct{})}
+}
+
+// put the index of the key, and add the key to the key set.
+func (s *StrIndex) put(key []byte, idx *index.Indexer) {
+	s.idxList.Put(key, idx)
+	s.keys.Add(string(key))
+}
+
+// remove the index of the key, and remove the key from the key set.
+func (s *StrIndex) remove(key []byte) {
+	if s.idxList.Remove(key) != nil {
+		s.keys.Remove(string(key))
+	}
 }
 
 func (db *FastDB) Set(key, value []byte) error {
@@ -25,9 +43,170 @@ func (db *FastDB) Get(key []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	db.strIndex.mu.RLock()
+	val, err := db.getVal(key)
+	db.strIndex.mu.RUnlock()
+
+	// The expired key is deleted here, since it needs the write lock.
+	if err == ErrKeyExpired {
+		db.strIndex.mu.Lock()
+		db.checkExpired(key, String)
+		db.strIndex.mu.Unlock()
+	}
+	return val, err
+}
+
+// SetEx set key to hold the string value and set key to timeout after a given number of seconds.
+func (db *FastDB) SetEx(key, value []byte, duration int64) (err error) {
+	if err = db.checkKeyValue(key, value); err != nil {
+		return
+	}
+	if duration <= 0 {
+		return ErrInvalidTTL
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	return db.setValEx(key, value, time.Now().Unix()+duration)
+}
+
+// SetNx set key to hold the string value only if key does not exist, returns whether the value is set.
+func (db *FastDB) SetNx(key, value []byte) (ok bool, err error) {
+	if err = db.checkKeyValue(key, value); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	// the expired key is treated as not existing.
+	db.checkExpired(key, String)
+	if db.strIndex.idxList.Exist(key) {
+		return
+	}
+
+	if err = db.setVal(key, value, StringSet); err == nil {
+		ok = true
+	}
+	return
+}
+
+// GetSet set key to value and returns the old value stored at key, the old value is nil if key does not exist.
+func (db *FastDB) GetSet(key, value []byte) (val []byte, err error) {
+	if err = db.checkKeyValue(key, value); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.checkExpired(key, String)
+	if val, err = db.getVal(key); err != nil && err != ErrKeyNotExist {
+		return
+	}
+
+	err = db.setVal(key, value, StringSet)
+	return
+}
+
+// Append appends the value at the end of the string stored at key, if key does not exist it is set to value.
+// The timeout of key is kept. It returns the length of the string after the append operation.
+func (db *FastDB) Append(key, value []byte) (length int, err error) {
+	if err = db.checkKeyValue(key, value); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.checkExpired(key, String)
+	oldVal, err := db.getVal(key)
+	if err != nil && err != ErrKeyNotExist {
+		return
+	}
+
+	newVal := make([]byte, 0, len(oldVal)+len(value))
+	newVal = append(append(newVal, oldVal...), value...)
+	if err = db.checkKeyValue(key, newVal); err != nil {
+		return
+	}
+
+	if deadline, expiring := db.expires[String][string(key)]; expiring {
+		err = db.setValEx(key, newVal, deadline)
+	} else {
+		err = db.setVal(key, newVal, StringSet)
+	}
+	if err != nil {
+		return
+	}
+	return len(newVal), nil
+}
+
+// Expire set the expiration time of the key, in seconds.
+func (db *FastDB) Expire(key []byte, duration int64) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if duration <= 0 {
+		return ErrInvalidTTL
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	if !db.strIndex.idxList.Exist(key) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, String) {
+		return ErrKeyExpired
+	}
+	return db.expire(key, time.Now().Unix()+duration, String)
+}
+
+// Persist remove the existing timeout on key.
+func (db *FastDB) Persist(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	var val []byte
+	if val, err = db.getVal(key); err != nil {
+		if err == ErrKeyExpired {
+			db.checkExpired(key, String)
+		}
+		return
+	}
+
+	if _, ok := db.expires[String][string(key)]; !ok {
+		return
+	}
+	// the value is saved again, so the index can point to the persist entry.
+	return db.setVal(key, val, StringPersist)
+}
+
+// TTL returns the remaining time to live of a key that has a timeout, in seconds.
+// It returns -1 if the key exists but has no associated expire, and -2 if the key does not exist.
+func (db *FastDB) TTL(key []byte) (ttl int64) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return -2
+	}
+
 	db.strIndex.mu.RLock()
 	defer db.strIndex.mu.RUnlock()
 
+	if !db.strIndex.idxList.Exist(key) || db.isExpired(key, String) {
+		return -2
+	}
+
+	return db.ttl(key, String)
+}
+
+// get the value of key, the caller should hold the lock of strIndex.
+func (db *FastDB) getVal(key []byte) ([]byte, error) {
 	// Get index info from a skip list in memory.
 	node := db.strIndex.idxList.Get(key)
 	if node == nil {
@@ -40,10 +219,14 @@ func (db *FastDB) Get(key []byte) ([]byte, error) {
 	}
 
 	// Check if the key is expired.
-	if db.checkExpired(key, String) {
+	if db.isExpired(key, String) {
 		return nil, ErrKeyExpired
 	}
+	return db.getIdxVal(idx)
+}
 
+// get the value of an indexer, the value is read from db file in KeyOnlyMemMode.
+func (db *FastDB) getIdxVal(idx *index.Indexer) ([]byte, error) {
 	// In KeyValueMemMode, the value will be stored in memory.
 	// So get the value from the index info.
 	if db.config.IdxMode == KeyValueMemMode {
@@ -53,12 +236,7 @@ func (db *FastDB) Get(key []byte) ([]byte, error) {
 	// In KeyOnlyMemMode, the value not in memory.
 	// So get the value from the db file at the offset.
 	if db.config.IdxMode == KeyOnlyMemMode {
-		df := db.activeFile[String]
-
-		if idx.FileId != db.activeFileIds[String] {
-			df = db.archFiles[String][idx.FileId]
-		}
-
+		df := db.getDBFile(String, idx.FileId)
 		e, err := df.Read(idx.Offset)
 		if err != nil {
 			return nil, err
@@ -73,25 +251,47 @@ func (db *FastDB) doSet(key, value []byte) (err error) {
 	if err = db.checkKeyValue(key, value); err != nil {
 		return err
 	}
-	// If the existed value is the same as the set value, nothing will be done.
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	// If the existed value is the same as the set value, nothing will be written, but the watchers still see the key set.
+	// And the key with a timeout should be set again to remove the timeout.
 	if db.config.IdxMode == KeyValueMemMode {
-		if existVal, _ := db.Get(key); existVal != nil && bytes.Compare(existVal, value) == 0 {
+		_, expiring := db.expires[String][string(key)]
+		if existVal, _ := db.getVal(key); !expiring && existVal != nil && bytes.Compare(existVal, value) == 0 {
+			db.oracle.record(String, key)
 			return
 		}
 	}
 
-	db.strIndex.mu.Lock()
-	defer db.strIndex.mu.Unlock()
+	return db.setVal(key, value, StringSet)
+}
 
-	e := storage.NewEntryNoExtra(key, value, String, StringSet)
+// write the entry of key and value, then update the string indexes.
+func (db *FastDB) setVal(key, value []byte, mark uint16) error {
+	return db.setEntry(storage.NewEntryNoExtra(key, value, String, mark))
+}
+
+// write the value and the deadline of key in one entry, so the value is never kept without its timeout.
+func (db *FastDB) setValEx(key, value []byte, deadline int64) error {
+	if err := db.setEntry(storage.NewEntryWithExpire(key, value, deadline, String, StringSetEx)); err != nil {
+		return err
+	}
+	db.expires[String][string(key)] = deadline
+	return nil
+}
+
+// write the String entry, then update the string indexes.
+func (db *FastDB) setEntry(e *storage.Entry) error {
 	if err := db.store(e); err != nil {
 		return err
 	}
 
-	db.incrReclaimableSpace(key)
+	db.incrReclaimableSpace(e.Meta.Key)
 	// clear expire time.
-	if _, ok := db.expires[String][string(key)]; ok {
-		delete(db.expires[String], string(key))
+	if _, ok := db.expires[String][string(e.Meta.Key)]; ok {
+		delete(db.expires[String], string(e.Meta.Key))
 	}
 
 	// string indexes, stored in skiplist.
@@ -109,9 +309,8 @@ func (db *FastDB) doSet(key, value []byte) (err error) {
 	if db.config.IdxMode == KeyValueMemMode {
 		idx.Meta.Value = e.Meta.Value
 	}
-	db.strIndex.idxList.Put(idx.Meta.Key, idx)
-	return
-
+	db.strIndex.put(idx.Meta.Key, idx)
+	return nil
 }
 
 func (db *FastDB) incrReclaimableSpace(key []byte) {
@@ -137,7 +336,7 @@ func (db *FastDB) StrLen(key []byte) int {
 
 	e := db.strIndex.idxList.Get(key)
 	if e != nil {
-		if db.checkExpired(key, String) {
+		if db.isExpired(key, String) {
 			return 0
 		}
 		idx := e.Value().(*index.Indexer)
@@ -156,7 +355,7 @@ func (db *FastDB) StrExists(key []byte) bool {
 	defer db.strIndex.mu.RUnlock()
 
 	exist := db.strIndex.idxList.Exist(key)
-	if exist && !db.checkExpired(key, String) {
+	if exist && !db.isExpired(key, String) {
 		return true
 	}
 	return false
@@ -177,7 +376,74 @@ func (db *FastDB) StrRem(key []byte) error {
 	}
 
 	db.incrReclaimableSpace(key)
-	db.strIndex.idxList.Remove(key)
+	db.strIndex.remove(key)
 	delete(db.expires[String], string(key))
 	return nil
 }
+
+// PrefixScan find the values of all the keys which match the prefix, in the order of keys.
+// limit and offset control the range of values, if limit is negative, all the matched values will be returned.
+func (db *FastDB) PrefixScan(prefix string, limit, offset int) (values [][]byte, err error) {
+	if limit == 0 {
+		return
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if err = db.checkKeyValue([]byte(prefix), nil); err != nil {
+		return
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	// Find the first matched key of the prefix.
+	e := db.strIndex.idxList.Seek([]byte(prefix))
+	for ; e != nil && bytes.HasPrefix(e.Key(), []byte(prefix)) && limit != 0; e = e.Next() {
+		if db.isExpired(e.Key(), String) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		var val []byte
+		if val, err = db.getIdxVal(e.Value().(*index.Indexer)); err != nil {
+			return
+		}
+		values = append(values, val)
+		if limit > 0 {
+			limit--
+		}
+	}
+	return
+}
+
+// RangeScan find the values of the keys between start and end (including start and end), in the order of keys.
+func (db *FastDB) RangeScan(start, end []byte) (values [][]byte, err error) {
+	if err = db.checkKeyValue(start, nil); err != nil {
+		return
+	}
+	if err = db.checkKeyValue(end, nil); err != nil {
+		return
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	// Seek returns the first key which is not less than start.
+	e := db.strIndex.idxList.Seek(start)
+	for ; e != nil && bytes.Compare(e.Key(), end) <= 0; e = e.Next() {
+		if db.isExpired(e.Key(), String) {
+			continue
+		}
+
+		var val []byte
+		if val, err = db.getIdxVal(e.Value().(*index.Indexer)); err != nil {
+			return
+		}
+		values = append(values, val)
+	}
+	return
+}
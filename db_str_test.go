@@ -1,93 +1,256 @@
 package fastdb
 
 import (
-	"log"
+	"fastdb/storage"
+	"fmt"
 	"math/rand"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestFastDB_Set(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
 
 	t.Run("normal situation", func(t *testing.T) {
-		db := InitDb()
-		defer db.Close()
-
-		db.Set(nil, nil)
-
-		err := db.Set([]byte("test_key"), []byte("I am roseduan"))
-		if err != nil {
-			log.Fatal("write data error ", err)
-		}
+		assert.Equal(t, ErrEmptyKey, db.Set(nil, nil))
+		assert.Nil(t, db.Set([]byte("test_key"), []byte("I am roseduan")))
 	})
 
 	t.Run("reopen and set", func(t *testing.T) {
-		db := ReopenDb()
-		defer db.Close()
-
-		db.Set([]byte("test_key001"), []byte("test_val001"))
-		db.Set([]byte("test_key002"), []byte("test_val002"))
+		db = CloseAndReopen(t, db)
+		assert.Nil(t, db.Set([]byte("test_key001"), []byte("test_val001")))
+		assert.Nil(t, db.Set([]byte("test_key002"), []byte("test_val002")))
 	})
 
 	t.Run("large data", func(t *testing.T) {
-		db := ReopenDb()
-		defer db.Close()
-
+		db = CloseAndReopen(t, db)
 		for i := 0; i < 100; i++ {
 			key := "k---" + strconv.Itoa(rand.Intn(100000))
 			val := "v---" + strconv.Itoa(rand.Intn(100000))
-			err := db.Set([]byte(key), []byte(val))
-			if err != nil {
-				log.Println("数据写入发生错误 ", err)
-			}
+			assert.Nil(t, db.Set([]byte(key), []byte(val)))
 		}
 	})
+	_ = db.Close()
 }
 
 func TestFastDB_Get(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+	_ = db.Set([]byte("test_key"), []byte("I am roseduan"))
+	_ = db.Set([]byte("test_key001"), []byte("test_val001"))
 
 	t.Run("normal situation", func(t *testing.T) {
-		db := ReopenDb()
-		defer db.Close()
-
-		db.Get(nil)
-		db.Get([]byte("hahahaha"))
+		_, err := db.Get(nil)
+		assert.Equal(t, ErrEmptyKey, err)
+		_, err = db.Get([]byte("hahahaha"))
+		assert.Equal(t, ErrKeyNotExist, err)
 
 		val, err := db.Get([]byte("test_key"))
-		if err != nil {
-			log.Fatal("get val error : ", err)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("I am roseduan"), val)
+	})
+
+	t.Run("reopen and get", func(t *testing.T) {
+		db = CloseAndReopen(t, db)
+		val, err := db.Get([]byte("test_key001"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("test_val001"), val)
+	})
+	_ = db.Close()
+}
+
+func TestFastDB_Expire(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+	defer db.Close()
+
+	assert.Equal(t, ErrKeyNotExist, db.Expire([]byte("not_exist"), 10))
+
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	assert.Equal(t, ErrInvalidTTL, db.Expire([]byte("k1"), 0))
+	assert.Nil(t, db.Expire([]byte("k1"), 100))
+
+	ttl := db.TTL([]byte("k1"))
+	assert.True(t, ttl > 98 && ttl <= 100)
+
+	// set the key again will remove the timeout.
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	assert.Equal(t, int64(-1), db.TTL([]byte("k1")))
+	assert.Equal(t, int64(-2), db.TTL([]byte("not_exist")))
+
+	// the deadline has passed.
+	db.strIndex.mu.Lock()
+	assert.Nil(t, db.expire([]byte("k1"), time.Now().Unix()-1, String))
+	db.strIndex.mu.Unlock()
+
+	assert.Equal(t, int64(-2), db.TTL([]byte("k1")))
+	_, err := db.Get([]byte("k1"))
+	assert.Equal(t, ErrKeyExpired, err)
+	_, err = db.Get([]byte("k1"))
+	assert.Equal(t, ErrKeyNotExist, err)
+}
+
+func TestFastDB_Persist(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+	defer db.Close()
+
+	assert.Equal(t, ErrKeyNotExist, db.Persist([]byte("not_exist")))
+
+	_ = db.SetEx([]byte("k1"), []byte("v1"), 100)
+	assert.Nil(t, db.Persist([]byte("k1")))
+	assert.Equal(t, int64(-1), db.TTL([]byte("k1")))
+
+	val, err := db.Get([]byte("k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), val)
+}
+
+func TestFastDB_Expire_Reopen(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+
+	_ = db.SetEx([]byte("expiring"), []byte("v1"), 100)
+	_ = db.SetEx([]byte("persisted"), []byte("v2"), 100)
+	_ = db.Persist([]byte("persisted"))
+	_ = db.SetEx([]byte("reset"), []byte("v3"), 100)
+	_ = db.Set([]byte("reset"), []byte("v4"))
+	_ = db.Set([]byte("expired"), []byte("v5"))
+	db.strIndex.mu.Lock()
+	_ = db.expire([]byte("expired"), time.Now().Unix()-1, String)
+	db.strIndex.mu.Unlock()
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+
+	ttl := db.TTL([]byte("expiring"))
+	assert.True(t, ttl > 98 && ttl <= 100)
+	assert.Equal(t, int64(-1), db.TTL([]byte("persisted")))
+	assert.Equal(t, int64(-1), db.TTL([]byte("reset")))
+	assert.False(t, db.StrExists([]byte("expired")))
+
+	val, _ := db.Get([]byte("persisted"))
+	assert.Equal(t, []byte("v2"), val)
+	val, _ = db.Get([]byte("reset"))
+	assert.Equal(t, []byte("v4"), val)
+}
+
+func TestFastDB_SetExOneEntry(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+
+	// the value and its timeout are written in one entry.
+	offset := db.activeFile[String].Offset
+	assert.Nil(t, db.SetEx([]byte("k1"), []byte("v1"), 100))
+	e := storage.NewEntryWithExpire([]byte("k1"), []byte("v1"), 0, String, StringSetEx)
+	assert.Equal(t, offset+int64(e.Size()), db.activeFile[String].Offset)
+	_, _ = db.Append([]byte("k1"), []byte("v2"))
+	assert.Equal(t, offset+int64(e.Size())*2+2, db.activeFile[String].Offset)
+
+	db.strIndex.mu.Lock()
+	_ = db.setValEx([]byte("expired"), []byte("v3"), time.Now().Unix()-1)
+	db.strIndex.mu.Unlock()
+
+	// the entry is kept after reclaiming.
+	for i := 0; i < 1000; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%d", i%10)), []byte(fmt.Sprintf("val-%05d", i)))
+	}
+	assert.Nil(t, db.Reclaim())
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+
+	val, _ := db.Get([]byte("k1"))
+	assert.Equal(t, []byte("v1v2"), val)
+	ttl := db.TTL([]byte("k1"))
+	assert.True(t, ttl > 98 && ttl <= 100)
+	assert.False(t, db.StrExists([]byte("expired")))
+}
+
+func TestFastDB_SetNxGetSetAppend(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+
+	ok, err := db.SetNx([]byte("k1"), []byte("v1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = db.SetNx([]byte("k1"), []byte("v2"))
+	assert.False(t, ok)
+
+	old, err := db.GetSet([]byte("k1"), []byte("v3"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), old)
+	old, err = db.GetSet([]byte("k2"), []byte("v1"))
+	assert.Nil(t, err)
+	assert.Nil(t, old)
+
+	// the timeout is kept after appending.
+	_ = db.SetEx([]byte("k3"), []byte("hello"), 100)
+	length, err := db.Append([]byte("k3"), []byte(" world"))
+	assert.Nil(t, err)
+	assert.Equal(t, 11, length)
+	length, _ = db.Append([]byte("k4"), []byte("new"))
+	assert.Equal(t, 3, length)
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+
+	val, _ := db.Get([]byte("k1"))
+	assert.Equal(t, []byte("v3"), val)
+	val, _ = db.Get([]byte("k3"))
+	assert.Equal(t, []byte("hello world"), val)
+	ttl := db.TTL([]byte("k3"))
+	assert.True(t, ttl > 98 && ttl <= 100)
+	val, _ = db.Get([]byte("k4"))
+	assert.Equal(t, []byte("new"), val)
+}
+
+func TestFastDB_PrefixScan(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := InitTestDb(t, mode)
+
+		_ = db.Set([]byte("ac"), []byte("0"))
+		for i := 1; i <= 5; i++ {
+			_ = db.Set([]byte(fmt.Sprintf("acc-%d", i)), []byte(strconv.Itoa(i)))
 		}
+		_ = db.Set([]byte("ad"), []byte("6"))
 
-		t.Log(string(val))
+		db.strIndex.mu.Lock()
+		_ = db.expire([]byte("acc-2"), time.Now().Unix()-1, String)
+		db.strIndex.mu.Unlock()
 
-		val, _ = db.Get([]byte("test_key001"))
-		t.Log(string(val))
+		values, err := db.PrefixScan("acc", -1, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, [][]byte{[]byte("1"), []byte("3"), []byte("4"), []byte("5")}, values)
 
-		val, _ = db.Get([]byte("test_key002"))
-		t.Log(string(val))
-	})
+		values, _ = db.PrefixScan("acc", 2, 1)
+		assert.Equal(t, [][]byte{[]byte("3"), []byte("4")}, values)
 
-	t.Run("reopen and get", func(t *testing.T) {
-		db := ReopenDb()
+		values, _ = db.PrefixScan("acc", 0, 0)
+		assert.Nil(t, values)
+		values, _ = db.PrefixScan("b", -1, 0)
+		assert.Nil(t, values)
 
-		val, _ := db.Get([]byte("test_key"))
-		log.Println(string(val))
-	})
+		_ = db.Close()
+	}
+}
+
+func TestFastDB_RangeScan(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := InitTestDb(t, mode)
+
+		for i := 0; i < 10; i++ {
+			_ = db.Set([]byte(fmt.Sprintf("key-%d", i)), []byte(strconv.Itoa(i)))
+		}
+		_ = db.StrRem([]byte("key-4"))
+
+		values, err := db.RangeScan([]byte("key-2"), []byte("key-5"))
+		assert.Nil(t, err)
+		assert.Equal(t, [][]byte{[]byte("2"), []byte("3"), []byte("5")}, values)
+
+		values, _ = db.RangeScan([]byte("key-8"), []byte("zzz"))
+		assert.Equal(t, [][]byte{[]byte("8"), []byte("9")}, values)
+
+		values, _ = db.RangeScan([]byte("zzz"), []byte("zzzz"))
+		assert.Nil(t, values)
 
-	//t.Run("large data", func(t *testing.T) {
-	//	now := time.Now()
-	//	db := ReopenDb()
-	//	t.Log("reopen db time spent : ", time.Since(now))
-	//
-	//	defer db.Close()
-	//
-	//	val, _ := db.Get([]byte("test_key_001"))
-	//	t.Log(string(val))
-	//
-	//	val, _ = db.Get([]byte("test_key_534647"))
-	//	t.Log(string(val))
-	//
-	//	val, _ = db.Get([]byte("test_key_378893"))
-	//	t.Log(string(val))
-	//})
+		_ = db.Close()
+	}
 }
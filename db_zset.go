@@ -0,0 +1,419 @@
+package fastdb
+
+import (
+	"encoding/binary"
+	"fastdb/ds/zset"
+	"fastdb/storage"
+	"math"
+	"sync"
+	"time"
+)
+
+// ZsetIdx the zset idx
+type ZsetIdx struct {
+	mu      sync.RWMutex
+	indexes *zset.SortedSet
+}
+
+func newZsetIdx() *ZsetIdx {
+	return &ZsetIdx{indexes: zset.New()}
+}
+
+// ZAdd adds the specified member with the specified score to the sorted set stored at key.
+// It returns 1 if the member is added, and 0 if the member exists and only its score is updated.
+func (db *FastDB) ZAdd(key []byte, score float64, member []byte) (res int, err error) {
+	if err = db.checkKeyValue(key, member); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	// the expired key is cleared, then a new one is created.
+	db.checkExpired(key, ZSet)
+
+	// If the existed score is the same as the new score, nothing will be done.
+	exist, oldScore := db.zScore(key, member)
+	if exist && oldScore == score {
+		return
+	}
+
+	e := storage.NewEntry(key, member, encodeScore(score), ZSet, ZSetZAdd)
+	if err = db.store(e); err != nil {
+		return
+	}
+
+	db.zsetIndex.indexes.ZAdd(string(key), score, string(member))
+	if !exist {
+		res = 1
+	}
+	return
+}
+
+// ZScore returns the score of member in the sorted set at key.
+func (db *FastDB) ZScore(key, member []byte) (ok bool, score float64) {
+	if err := db.checkKeyValue(key, member); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return
+	}
+
+	return db.zScore(key, member)
+}
+
+// ZCard returns the sorted set cardinality (number of elements) of the sorted set stored at key.
+func (db *FastDB) ZCard(key []byte) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return 0
+	}
+
+	return db.zsetIndex.indexes.ZCard(string(key))
+}
+
+// ZRank returns the rank of member in the sorted set stored at key, with the scores ordered from low to high.
+// The rank (or index) is 0-based, which means that the member with the lowest score has rank 0.
+// It returns -1 if the member does not exist.
+func (db *FastDB) ZRank(key, member []byte) int64 {
+	if err := db.checkKeyValue(key, member); err != nil {
+		return -1
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return -1
+	}
+
+	return db.zsetIndex.indexes.ZRank(string(key), string(member))
+}
+
+// ZRevRank returns the rank of member in the sorted set stored at key, with the scores ordered from high to low.
+// The rank (or index) is 0-based, which means that the member with the highest score has rank 0.
+// It returns -1 if the member does not exist.
+func (db *FastDB) ZRevRank(key, member []byte) int64 {
+	if err := db.checkKeyValue(key, member); err != nil {
+		return -1
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return -1
+	}
+
+	return db.zsetIndex.indexes.ZRevRank(string(key), string(member))
+}
+
+// ZIncrBy increments the score of member in the sorted set stored at key by increment.
+// If member does not exist in the sorted set, it is added with increment as its score (as if its previous score was 0.0).
+// If key does not exist, a new sorted set with the specified member as its sole member is created.
+func (db *FastDB) ZIncrBy(key []byte, increment float64, member []byte) (float64, error) {
+	if err := db.checkKeyValue(key, member); err != nil {
+		return increment, err
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	// the expired key is cleared, then a new one is created.
+	db.checkExpired(key, ZSet)
+
+	if ok, oldScore := db.zScore(key, member); ok {
+		increment += oldScore
+	}
+
+	// the final score is saved, so the replay doesn`t depend on the previous value.
+	e := storage.NewEntry(key, member, encodeScore(increment), ZSet, ZSetZAdd)
+	if err := db.store(e); err != nil {
+		return increment, err
+	}
+
+	db.zsetIndex.indexes.ZAdd(string(key), increment, string(member))
+	return increment, nil
+}
+
+// ZRange returns the specified range of elements in the sorted set stored at key.
+func (db *FastDB) ZRange(key []byte, start, stop int) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZRange(string(key), start, stop)
+}
+
+// ZRangeWithScores returns the specified range of elements with scores in the sorted set stored at key.
+func (db *FastDB) ZRangeWithScores(key []byte, start, stop int) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZRangeWithScores(string(key), start, stop)
+}
+
+// ZRevRange returns the specified range of elements in the sorted set stored at key.
+// The elements are considered to be ordered from the highest to the lowest score.
+// Descending lexicographical order is used for elements with equal score.
+func (db *FastDB) ZRevRange(key []byte, start, stop int) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZRevRange(string(key), start, stop)
+}
+
+// ZRevRangeWithScores returns the specified range of elements with scores in the sorted set stored at key.
+// The elements are considered to be ordered from the highest to the lowest score.
+func (db *FastDB) ZRevRangeWithScores(key []byte, start, stop int) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZRevRangeWithScores(string(key), start, stop)
+}
+
+// ZRem removes the specified members from the sorted set stored at key. Non existing members are ignored.
+func (db *FastDB) ZRem(key, member []byte) (ok bool, err error) {
+	if err = db.checkKeyValue(key, member); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if db.checkExpired(key, ZSet) {
+		return false, ErrKeyExpired
+	}
+
+	if ok = db.zsetIndex.indexes.ZRem(string(key), string(member)); ok {
+		e := storage.NewEntryNoExtra(key, member, ZSet, ZSetZRem)
+		if err = db.store(e); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ZGetByRank get the member at key by rank, the rank is ordered from lowest to highest.
+// The rank of lowest is 0 and so on.
+func (db *FastDB) ZGetByRank(key []byte, rank int) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZGetByRank(string(key), rank)
+}
+
+// ZRevGetByRank get the member at key by rank, the rank is ordered from highest to lowest.
+// The rank of highest is 0 and so on.
+func (db *FastDB) ZRevGetByRank(key []byte, rank int) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZRevGetByRank(string(key), rank)
+}
+
+// ZScoreRange returns all the elements in the sorted set at key with a score between min and max (including elements with score equal to min or max).
+// The elements are considered to be ordered from low to high scores.
+func (db *FastDB) ZScoreRange(key []byte, min, max float64) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZScoreRange(string(key), min, max)
+}
+
+// ZRevScoreRange returns all the elements in the sorted set at key with a score between max and min (including elements with score equal to max or min).
+// In contrary to the default ordering of sorted sets, for this command the elements are considered to be ordered from high to low scores.
+func (db *FastDB) ZRevScoreRange(key []byte, max, min float64) []interface{} {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
+	return db.zsetIndex.indexes.ZRevScoreRange(string(key), max, min)
+}
+
+// ZKeyExists check if the key exists in zset.
+func (db *FastDB) ZKeyExists(key []byte) (ok bool) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return
+	}
+
+	return db.zsetIndex.indexes.ZKeyExists(string(key))
+}
+
+// ZClear clear the specified key in zset.
+func (db *FastDB) ZClear(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if db.checkExpired(key, ZSet) || !db.zsetIndex.indexes.ZKeyExists(string(key)) {
+		return
+	}
+
+	e := storage.NewEntryNoExtra(key, nil, ZSet, ZSetZClear)
+	if err = db.store(e); err != nil {
+		return
+	}
+	db.zsetIndex.indexes.ZClear(string(key))
+	delete(db.expires[ZSet], string(key))
+	return
+}
+
+// ZExpire set the expiration time of the sorted set key, in seconds.
+func (db *FastDB) ZExpire(key []byte, duration int64) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if duration <= 0 {
+		return ErrInvalidTTL
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if !db.zsetIndex.indexes.ZKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, ZSet) {
+		return ErrKeyExpired
+	}
+	return db.expire(key, time.Now().Unix()+duration, ZSet)
+}
+
+// ZPersist remove the existing timeout on the sorted set key.
+func (db *FastDB) ZPersist(key []byte) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if !db.zsetIndex.indexes.ZKeyExists(string(key)) {
+		return ErrKeyNotExist
+	}
+	if db.checkExpired(key, ZSet) {
+		return ErrKeyExpired
+	}
+	return db.persist(key, ZSet)
+}
+
+// ZTTL returns the remaining time to live of the sorted set key, in seconds.
+// It returns -1 if the key exists but has no associated expire, and -2 if the key does not exist.
+func (db *FastDB) ZTTL(key []byte) (ttl int64) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return -2
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if !db.zsetIndex.indexes.ZKeyExists(string(key)) || db.isExpired(key, ZSet) {
+		return -2
+	}
+	return db.ttl(key, ZSet)
+}
+
+// get the score of member, ok is false if the member does not exist.
+func (db *FastDB) zScore(key, member []byte) (ok bool, score float64) {
+	if db.zsetIndex.indexes.ZRank(string(key), string(member)) == -1 {
+		return
+	}
+	return true, db.zsetIndex.indexes.ZScore(string(key), string(member))
+}
+
+// encode the score as the raw float64 bits, so it is restored without any precision loss.
+func encodeScore(score float64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(score))
+	return buf
+}
+
+func decodeScore(buf []byte) (float64, bool) {
+	if len(buf) != 8 {
+		return 0, false
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(buf)), true
+}
@@ -0,0 +1,83 @@
+package fastdb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastDB_ZAdd(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_, err := db.ZAdd(nil, 1, []byte("a"))
+	assert.Equal(t, ErrEmptyKey, err)
+
+	_, _ = db.ZAdd([]byte("myzset"), 3, []byte("c"))
+	_, _ = db.ZAdd([]byte("myzset"), 1, []byte("a"))
+	res, err := db.ZAdd([]byte("myzset"), 5, []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res)
+
+	// only the score is updated if the member exists.
+	res, err = db.ZAdd([]byte("myzset"), 2, []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, res)
+	res, _ = db.ZAdd([]byte("myzset"), 2, []byte("b"))
+	assert.Equal(t, 0, res)
+
+	assert.Equal(t, 3, db.ZCard([]byte("myzset")))
+	assert.Equal(t, int64(0), db.ZRank([]byte("myzset"), []byte("a")))
+	assert.Equal(t, int64(0), db.ZRevRank([]byte("myzset"), []byte("c")))
+	assert.Equal(t, int64(-1), db.ZRank([]byte("myzset"), []byte("not_exist")))
+	assert.Equal(t, []interface{}{"a", "b", "c"}, db.ZRange([]byte("myzset"), 0, -1))
+}
+
+func TestFastDB_ZScore(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	ok, _ := db.ZScore([]byte("myzset"), []byte("a"))
+	assert.False(t, ok)
+
+	_, _ = db.ZAdd([]byte("myzset"), -2.5, []byte("a"))
+	ok, score := db.ZScore([]byte("myzset"), []byte("a"))
+	assert.True(t, ok)
+	assert.Equal(t, -2.5, score)
+
+	res, err := db.ZIncrBy([]byte("myzset"), 10, []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, 7.5, res)
+}
+
+func TestFastDB_ZSet_Reopen(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+
+	key := []byte("myzset")
+	// scores which can`t be represented exactly by a short decimal string.
+	scores := []float64{0.1 + 0.2, math.Pi, -math.MaxFloat64, math.SmallestNonzeroFloat64}
+	members := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	for i := range scores {
+		_, _ = db.ZAdd(key, scores[i], members[i])
+	}
+	_, _ = db.ZIncrBy(key, 1.1, []byte("b"))
+	ok, err := db.ZRem(key, []byte("d"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, _ = db.ZAdd([]byte("cleared"), 1, []byte("a"))
+	_ = db.ZClear([]byte("cleared"))
+
+	before := db.ZRangeWithScores(key, 0, -1)
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+
+	assert.Equal(t, before, db.ZRangeWithScores(key, 0, -1))
+	_, score := db.ZScore(key, []byte("a"))
+	assert.Equal(t, scores[0], score)
+	_, score = db.ZScore(key, []byte("b"))
+	assert.Equal(t, scores[1]+1.1, score)
+	assert.Equal(t, 3, db.ZCard(key))
+	assert.False(t, db.ZKeyExists([]byte("cleared")))
+}
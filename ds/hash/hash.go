@@ -1,11 +1,14 @@
 package hash
 
+import "fastdb/index"
+
 // the implementation of hash table.
 
 type (
 	// Hash hash table struct.
 	Hash struct {
 		record Record
+		keys   *index.KeySet // the keys ordered by hash, for scanning.
 	}
 
 	// Record hash records to save.
@@ -14,7 +17,7 @@ type (
 
 // New create a new hash ds.
 func New() *Hash {
-	return &Hash{make(Record)}
+	return &Hash{record: make(Record), keys: index.NewKeySet()}
 }
 
 // HSet Sets field in the hash stored at key to value. If key does not exist, a new key holding a hash is created.
@@ -22,6 +25,7 @@ func New() *Hash {
 func (h *Hash) HSet(key string, field string, value []byte) (res int) {
 	if !h.exist(key) {
 		h.record[key] = make(map[string][]byte)
+		h.keys.Add(key)
 	}
 
 	if h.record[key][field] != nil {
@@ -41,6 +45,7 @@ func (h *Hash) HSet(key string, field string, value []byte) (res int) {
 func (h *Hash) HSetNx(key string, field string, value []byte) int {
 	if !h.exist(key) {
 		h.record[key] = make(map[string][]byte)
+		h.keys.Add(key)
 	}
 
 	if _, exist := h.record[key][field]; !exist {
@@ -141,6 +146,20 @@ func (h *Hash) HClear(key string) {
 		return
 	}
 	delete(h.record, key)
+	h.keys.Remove(key)
+}
+
+// Keys returns all the keys of the hash table.
+func (h *Hash) Keys() (keys []string) {
+	for k := range h.record {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// ScanKeys returns the keys after the cursor, see index.KeySet.Scan.
+func (h *Hash) ScanKeys(cursor uint64, count int) ([]string, uint64) {
+	return h.keys.Scan(cursor, count)
 }
 
 func (h *Hash) exist(key string) bool {
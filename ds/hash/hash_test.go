@@ -131,3 +131,15 @@ func TestHash_HKeyExists(t *testing.T) {
 	exists1 := hash.HKeyExists(key)
 	assert.Equal(t, exists1, false)
 }
+
+func TestHash_ScanKeys(t *testing.T) {
+	hash := New()
+	for _, k := range []string{"k3", "k1", "k4", "k2"} {
+		hash.HSet(k, "a", []byte("val"))
+	}
+	hash.HClear("k2")
+
+	keys, next := hash.ScanKeys(0, 10)
+	assert.ElementsMatch(t, []string{"k1", "k3", "k4"}, keys)
+	assert.Zero(t, next)
+}
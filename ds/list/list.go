@@ -2,6 +2,7 @@ package list
 
 import (
 	"container/list"
+	"fastdb/index"
 	"reflect"
 )
 
@@ -25,6 +26,9 @@ type (
 
 		// values saves the values of a List, help checking if a value exists in List.
 		values map[string]map[string]int
+
+		// keys saves the keys ordered by hash, for scanning.
+		keys *index.KeySet
 	}
 
 	// Record list record to save.
@@ -36,6 +40,7 @@ func New() *List {
 	return &List{
 		make(Record),
 		make(map[string]map[string]int),
+		index.NewKeySet(),
 	}
 }
 
@@ -120,8 +125,8 @@ func (lis *List) LRem(key string, val []byte, count int) int {
 	length := len(ele)
 	ele = nil
 
-	if lis.values[key] != nil {
-		cnt := lis.values[key][string(val)] - 1
+	if lis.values[key] != nil && length > 0 {
+		cnt := lis.values[key][string(val)] - length
 		if cnt <= 0 {
 			delete(lis.values[key], string(val))
 		} else {
@@ -302,6 +307,7 @@ func (lis *List) LLen(key string) int {
 func (lis *List) LClear(key string) {
 	delete(lis.record, key)
 	delete(lis.values, key)
+	lis.keys.Remove(key)
 }
 
 // LKeyExists check if the key of a List exists.
@@ -319,6 +325,19 @@ func (lis *List) LValExists(key string, val []byte) (ok bool) {
 	return
 }
 
+// Keys returns all the keys of List.
+func (lis *List) Keys() (keys []string) {
+	for k := range lis.record {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// ScanKeys returns the keys after the cursor, see index.KeySet.Scan.
+func (lis *List) ScanKeys(cursor uint64, count int) ([]string, uint64) {
+	return lis.keys.Scan(cursor, count)
+}
+
 func (lis *List) find(key string, val []byte) *list.Element {
 	item := lis.record[key]
 	var e *list.Element
@@ -367,6 +386,7 @@ func (lis *List) index(key string, index int) *list.Element {
 func (lis *List) push(front bool, key string, val ...[]byte) int {
 	if lis.record[key] == nil {
 		lis.record[key] = list.New()
+		lis.keys.Add(key)
 	}
 	if lis.values[key] == nil {
 		lis.values[key] = make(map[string]int)
@@ -3,6 +3,8 @@ package list
 import (
 	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var key = "my_list"
@@ -271,3 +273,15 @@ func TestList_LValExists(t *testing.T) {
 
 	PrintListData(lis)
 }
+
+func TestList_ScanKeys(t *testing.T) {
+	lis := New()
+	for _, k := range []string{"k3", "k1", "k2"} {
+		lis.RPush(k, []byte("a"))
+	}
+	lis.LClear("k2")
+
+	keys, next := lis.ScanKeys(0, 10)
+	assert.ElementsMatch(t, []string{"k1", "k3"}, keys)
+	assert.Zero(t, next)
+}
@@ -1,5 +1,7 @@
 package set
 
+import "fastdb/index"
+
 // Set is the implementation of set data structure.
 
 var existFlag = struct{}{}
@@ -8,6 +10,7 @@ type (
 	// Set set index.
 	Set struct {
 		record Record
+		keys   *index.KeySet // the keys ordered by hash, for scanning.
 	}
 
 	// Record records in set to save.
@@ -16,7 +19,7 @@ type (
 
 // New create a new set idx.
 func New() *Set {
-	return &Set{make(Record)}
+	return &Set{record: make(Record), keys: index.NewKeySet()}
 }
 
 // SAdd Add the specified members to the set stored at key.
@@ -25,6 +28,7 @@ func New() *Set {
 func (s *Set) SAdd(key string, member []byte) int {
 	if !s.exist(key) {
 		s.record[key] = make(map[string]struct{})
+		s.keys.Add(key)
 	}
 
 	s.record[key][string(member)] = existFlag
@@ -111,6 +115,7 @@ func (s *Set) SMove(src, dst string, member []byte) bool {
 
 	if !s.exist(dst) {
 		s.record[dst] = make(map[string]struct{})
+		s.keys.Add(dst)
 	}
 
 	delete(s.record[src], string(member))
@@ -187,7 +192,21 @@ func (s *Set) SKeyExists(key string) (ok bool) {
 func (s *Set) SClear(key string) {
 	if s.SKeyExists(key) {
 		delete(s.record, key)
+		s.keys.Remove(key)
+	}
+}
+
+// Keys returns all the keys of set.
+func (s *Set) Keys() (keys []string) {
+	for k := range s.record {
+		keys = append(keys, k)
 	}
+	return
+}
+
+// ScanKeys returns the keys after the cursor, see index.KeySet.Scan.
+func (s *Set) ScanKeys(cursor uint64, count int) ([]string, uint64) {
+	return s.keys.Scan(cursor, count)
 }
 
 // check the key of set is exist.
@@ -224,3 +224,16 @@ func TestNew(t *testing.T) {
 	set := New()
 	assert.NotEqual(t, set, nil)
 }
+
+func TestSet_ScanKeys(t *testing.T) {
+	set := New()
+	for _, k := range []string{"k3", "k1", "k2"} {
+		set.SAdd(k, []byte("a"))
+	}
+	set.SMove("k1", "k4", []byte("a"))
+	set.SClear("k2")
+
+	keys, next := set.ScanKeys(0, 10)
+	assert.ElementsMatch(t, []string{"k1", "k3", "k4"}, keys)
+	assert.Zero(t, next)
+}
@@ -1,6 +1,7 @@
 package zset
 
 import (
+	"fastdb/index"
 	"math"
 	"math/rand"
 )
@@ -16,6 +17,7 @@ type (
 	// SortedSet sorted set struct
 	SortedSet struct {
 		record map[string]*SortedSetNode
+		keys   *index.KeySet // the keys ordered by hash, for scanning.
 	}
 
 	// SortedSetNode node of sorted set
@@ -47,7 +49,8 @@ type (
 // New new a sorted set
 func New() *SortedSet {
 	return &SortedSet{
-		make(map[string]*SortedSetNode),
+		record: make(map[string]*SortedSetNode),
+		keys:   index.NewKeySet(),
 	}
 }
 
@@ -61,6 +64,7 @@ func (z *SortedSet) ZAdd(key string, score float64, member string) {
 			skl:  newSkipList(),
 		}
 		z.record[key] = node
+		z.keys.Add(key)
 	}
 
 	item := z.record[key]
@@ -342,9 +346,23 @@ func (z *SortedSet) ZKeyExists(key string) bool {
 func (z *SortedSet) ZClear(key string) {
 	if z.ZKeyExists(key) {
 		delete(z.record, key)
+		z.keys.Remove(key)
 	}
 }
 
+// Keys returns all the keys of zset.
+func (z *SortedSet) Keys() (keys []string) {
+	for k := range z.record {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// ScanKeys returns the keys after the cursor, see index.KeySet.Scan.
+func (z *SortedSet) ScanKeys(cursor uint64, count int) ([]string, uint64) {
+	return z.keys.Scan(cursor, count)
+}
+
 func (z *SortedSet) exist(key string) bool {
 	_, exist := z.record[key]
 	return exist
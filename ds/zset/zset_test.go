@@ -298,3 +298,15 @@ func TestSortedSet_ZKeyExists(t *testing.T) {
 	ok2 := zset.ZKeyExists(key)
 	assert.Equal(t, ok2, false)
 }
+
+func TestSortedSet_ScanKeys(t *testing.T) {
+	zset := New()
+	for _, k := range []string{"k3", "k1", "k2"} {
+		zset.ZAdd(k, 1, "a")
+	}
+	zset.ZClear("k1")
+
+	keys, next := zset.ScanKeys(0, 10)
+	assert.ElementsMatch(t, []string{"k2", "k3"}, keys)
+	assert.Zero(t, next)
+}
@@ -0,0 +1,111 @@
+package fastdb
+
+import (
+	"fastdb/storage"
+	"time"
+)
+
+const (
+	// the max number of keys sampled each time in an expiration cycle.
+	expireSampleKeys = 20
+
+	// if more than 1/4 of the sampled keys are expired, there may be more expired keys, so the sampling continues.
+	expireRepeatRatio = 4
+)
+
+// delete the expired keys periodically in background.
+func (db *FastDB) expireLoop() {
+	defer db.bgWg.Done()
+
+	ticker := time.NewTicker(db.config.ExpireCycleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.activeExpireCycle()
+		case <-db.closed:
+			return
+		}
+	}
+}
+
+// activeExpireCycle works like the active expire cycle of redis.
+// It samples the keys with a timeout of each data type and deletes the expired ones,
+// then returns when there are few expired keys, or the time budget is exhausted.
+func (db *FastDB) activeExpireCycle() {
+	start := time.Now()
+	for dType := 0; dType < DataStructureNum; dType++ {
+		for {
+			sampled, expired := db.expireSample(uint16(dType))
+			if sampled == 0 || expired*expireRepeatRatio <= sampled {
+				break
+			}
+			if time.Since(start) > db.config.ExpireCycleBudget {
+				return
+			}
+		}
+	}
+}
+
+// sample some keys with a timeout of the data type, and delete the expired ones.
+func (db *FastDB) expireSample(dType DataType) (sampled, expired int) {
+	mu := db.getIdxLock(dType)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the iteration order of map is random, so it is a sampling.
+	now := time.Now().Unix()
+	var keys []string
+	for k, deadline := range db.expires[dType] {
+		if now > deadline {
+			keys = append(keys, k)
+		}
+		if sampled++; sampled == expireSampleKeys {
+			break
+		}
+	}
+
+	for _, k := range keys {
+		if db.checkExpired([]byte(k), dType) {
+			expired++
+		}
+	}
+	return
+}
+
+// write the expire entry and save the deadline of key.
+// The caller should hold the write lock of the data type.
+func (db *FastDB) expire(key []byte, deadline int64, dType DataType) error {
+	e := storage.NewEntryWithExpire(key, nil, deadline, dType, expireMarks[dType])
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	db.expires[dType][string(key)] = deadline
+	return nil
+}
+
+// write the persist entry and remove the deadline of a List, Hash, Set or ZSet key.
+// The caller should hold the write lock of the data type.
+func (db *FastDB) persist(key []byte, dType DataType) error {
+	if _, ok := db.expires[dType][string(key)]; !ok {
+		return nil
+	}
+
+	e := storage.NewEntryNoExtra(key, nil, dType, persistMarks[dType])
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	delete(db.expires[dType], string(key))
+	return nil
+}
+
+// returns the remaining time to live of key, or -1 if the key has no timeout.
+func (db *FastDB) ttl(key []byte, dType DataType) int64 {
+	deadline, exist := db.expires[dType][string(key)]
+	if !exist {
+		return -1
+	}
+	return deadline - time.Now().Unix()
+}
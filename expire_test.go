@@ -0,0 +1,138 @@
+package fastdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastDB_ActiveExpireCycle(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	db.strIndex.mu.Lock()
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		_ = db.setVal(key, []byte("val"), StringSet)
+		deadline := time.Now().Unix() - 1
+		if i%10 == 0 {
+			deadline = time.Now().Unix() + 100
+		}
+		_ = db.expire(key, deadline, String)
+	}
+	db.strIndex.mu.Unlock()
+
+	db.activeExpireCycle()
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+	assert.Equal(t, 10, len(db.expires[String]))
+	assert.Equal(t, 10, db.strIndex.idxList.Len)
+	assert.True(t, db.meta.ReclaimableSpace[0] > 0)
+}
+
+func TestFastDB_ExpireInBackground(t *testing.T) {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.ExpireCycleInterval = 10 * time.Millisecond
+
+	db, err := Open(config)
+	assert.Nil(t, err)
+
+	db.strIndex.mu.Lock()
+	_ = db.setVal([]byte("k1"), []byte("v1"), StringSet)
+	_ = db.expire([]byte("k1"), time.Now().Unix()-1, String)
+	db.strIndex.mu.Unlock()
+
+	deleted := func() bool {
+		db.strIndex.mu.RLock()
+		defer db.strIndex.mu.RUnlock()
+		return !db.strIndex.idxList.Exist([]byte("k1"))
+	}
+	assert.Eventually(t, deleted, time.Second, 10*time.Millisecond)
+
+	// the expired key is removed from db files too.
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+	assert.Equal(t, 0, len(db.expires[String]))
+	assert.Equal(t, 0, db.strIndex.idxList.Len)
+}
+
+func TestFastDB_ExpireCollections(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+
+	key := []byte("my_key")
+	_, _ = db.RPush(key, []byte("a"), []byte("b"))
+	_, _ = db.HSet(key, []byte("field"), []byte("a"))
+	_, _ = db.SAdd(key, []byte("a"), []byte("b"))
+	_, _ = db.ZAdd(key, 1, []byte("a"))
+
+	assert.Equal(t, ErrInvalidTTL, db.LExpire(key, 0))
+	assert.Equal(t, ErrKeyNotExist, db.HExpire([]byte("not_exist"), 10))
+	assert.Equal(t, int64(-2), db.STTL([]byte("not_exist")))
+	assert.Equal(t, int64(-1), db.ZTTL(key))
+
+	assert.Nil(t, db.LExpire(key, 100))
+	assert.Nil(t, db.HExpire(key, 100))
+	assert.Nil(t, db.SExpire(key, 100))
+	assert.Nil(t, db.ZExpire(key, 100))
+	assert.True(t, db.LTTL(key) > 90)
+	assert.True(t, db.HTTL(key) > 90)
+
+	assert.Nil(t, db.SPersist(key))
+	assert.Equal(t, int64(-1), db.STTL(key))
+
+	// make the list, hash and zset expired.
+	for _, dType := range []DataType{List, Hash, ZSet} {
+		lock := db.getIdxLock(dType)
+		lock.Lock()
+		_ = db.expire(key, time.Now().Unix()-1, dType)
+		lock.Unlock()
+	}
+
+	assert.Equal(t, 0, db.LLen(key))
+	assert.Nil(t, db.HGet(key, []byte("field")))
+	assert.Equal(t, 0, db.ZCard(key))
+	assert.Equal(t, int64(-2), db.ZTTL(key))
+	assert.Equal(t, 2, db.SCard(key))
+
+	_, err := db.LPop(key)
+	assert.Equal(t, ErrKeyExpired, err)
+
+	// a write creates a new key without timeout.
+	_, _ = db.HSet(key, []byte("field2"), []byte("b"))
+	assert.Nil(t, db.HGet(key, []byte("field")))
+	assert.Equal(t, int64(-1), db.HTTL(key))
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+
+	assert.False(t, db.LKeyExists(key))
+	assert.False(t, db.ZKeyExists(key))
+	assert.Equal(t, []byte("b"), db.HGet(key, []byte("field2")))
+	assert.Equal(t, int64(-1), db.HTTL(key))
+	assert.Equal(t, int64(-1), db.STTL(key))
+	assert.Equal(t, 2, db.SCard(key))
+}
+
+func TestFastDB_ActiveExpireCycle_Collections(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	db.setIndex.mu.Lock()
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		db.setIndex.indexes.SAdd(string(key), []byte("member"))
+		_ = db.expire(key, time.Now().Unix()-1, Set)
+	}
+	db.setIndex.mu.Unlock()
+
+	db.activeExpireCycle()
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+	assert.Equal(t, 0, len(db.expires[Set]))
+	assert.False(t, db.setIndex.indexes.SKeyExists("key-0"))
+}
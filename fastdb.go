@@ -64,6 +64,15 @@ var (
 
 	// ErrDBisReclaiming reclaim and single reclaim can`t execute at the same time.
 	ErrDBisReclaiming = errors.New("rosedb: can`t do reclaim and single reclaim at the same time")
+
+	// ErrBatchClosed the write batch is committed or discarded
+	ErrBatchClosed = errors.New("rosedb: the write batch is committed or discarded")
+
+	// ErrTxnConflict the keys read by the transaction have been modified
+	ErrTxnConflict = errors.New("rosedb: transaction conflict, the keys read have been modified")
+
+	// ErrDBFileCorrupted a broken entry is found in the middle of the db files
+	ErrDBFileCorrupted = errors.New("rosedb: the db file is corrupted")
 )
 
 type (
@@ -74,12 +83,21 @@ type (
 		archFiles          ArchivedFiles   // The archived files.
 		strIndex           *StrIndex       // String indexes(a skip list).
 		hashIndex          *HashIdx        // Hash indexes.
+		listIndex          *ListIdx        // List indexes.
+		setIndex           *SetIdx         // Set indexes.
+		zsetIndex          *ZsetIdx        // Sorted set indexes.
 		config             Config          // Config info of rosedb.
 		mu                 sync.RWMutex    // mutex.
 		meta               *storage.DBMeta // Meta info for rosedb.
 		expires            Expires         // Expired directory..
 		isReclaiming       bool
 		isSingleReclaiming bool
+		closed             chan struct{}   // closed when the db is closed, stops the background goroutines.
+		closeOnce          sync.Once       // make sure the closed chan is closed only once.
+		bgWg               sync.WaitGroup  // wait for the background goroutines to exit.
+		lastBatchId        uint64          // the id of the last write batch, protected by mu.
+		oracle             *oracle         // tracks the keys written for the transactions.
+		strHints           []*storage.Hint // the hints of the entries in the active String file, saved when it is archived.
 	}
 
 	// ActiveFiles current active files for different data types.
@@ -116,55 +134,91 @@ func (db *FastDB) checkKeyValue(key []byte, value ...[]byte) error {
 	return nil
 }
 
-// Check whether key is expired and delete it if needed.
-func (db *FastDB) checkExpired(key []byte, dType DataType) (expired bool) {
+// Check whether key is expired, the key will not be deleted, so it can be used with the read lock.
+func (db *FastDB) isExpired(key []byte, dType DataType) bool {
 	deadline, exist := db.expires[dType][string(key)]
-	if !exist {
-		return
-	}
+	return exist && time.Now().Unix() > deadline
+}
 
-	if time.Now().Unix() > deadline {
+// Check whether key is expired and delete it if needed.
+// The caller should hold the write lock of the data type.
+func (db *FastDB) checkExpired(key []byte, dType DataType) (expired bool) {
+	if db.isExpired(key, dType) {
 		expired = true
-
-		var e *storage.Entry
-		switch dType {
-		case String:
-			e = storage.NewEntryNoExtra(key, nil, String, StringRem)
-			if ele := db.strIndex.idxList.Remove(key); ele != nil {
-				db.incrReclaimableSpace(key)
-			}
-		}
-		if err := db.store(e); err != nil {
+		if err := db.clearKey(key, dType); err != nil {
 			log.Println("checkExpired: store entry err: ", err)
-			return
 		}
-		// delete the expire info stored at key.
-		delete(db.expires[dType], string(key))
 	}
 	return
 }
 
+// write the entry removing the whole key, then remove it from the indexes of the data type.
+// The caller should hold the write lock of the data type.
+func (db *FastDB) clearKey(key []byte, dType DataType) error {
+	e := storage.NewEntryNoExtra(key, nil, dType, clearMarks[dType])
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	switch dType {
+	case String:
+		db.incrReclaimableSpace(key)
+		db.strIndex.remove(key)
+	case List:
+		db.listIndex.indexes.LClear(string(key))
+	case Hash:
+		db.hashIndex.indexes.HClear(string(key))
+	case Set:
+		db.setIndex.indexes.SClear(string(key))
+	case ZSet:
+		db.zsetIndex.indexes.ZClear(string(key))
+	}
+	// delete the expire info stored at key.
+	delete(db.expires[dType], string(key))
+	return nil
+}
+
+// get the lock of the indexes of the data type.
+func (db *FastDB) getIdxLock(dType DataType) *sync.RWMutex {
+	switch dType {
+	case List:
+		return &db.listIndex.mu
+	case Hash:
+		return &db.hashIndex.mu
+	case Set:
+		return &db.setIndex.mu
+	case ZSet:
+		return &db.zsetIndex.mu
+	default:
+		return &db.strIndex.mu
+	}
+}
+
 // write entry to db file.
 func (db *FastDB) store(e *storage.Entry) error {
+	// the running iterators and transactions should not see the modification.
+	if e.GetType() == String && !e.IsBatchCommit() {
+		db.saveStrSnapshots(e.Meta.Key)
+	}
+	if e.GetType() == Hash && !e.IsBatchCommit() {
+		db.saveHashSnapshots(e.Meta.Key)
+	}
+
+	// the active files are shared by all data types, so writing must be serialized.
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.writeEntry(e, db.config.Sync)
+}
+
+// write entry to the active file, the caller should hold db.mu.
+func (db *FastDB) writeEntry(e *storage.Entry, sync bool) error {
 	// sync the db file if file size is not enough, and open a new db file.
 	config := db.config
 	if db.activeFile[e.GetType()].Offset+int64(e.Size()) > config.BlockSize {
-		if err := db.activeFile[e.GetType()].Sync(); err != nil {
+		if err := db.archiveActiveFile(e.GetType()); err != nil {
 			return err
 		}
-
-		// save the old db file as arched file.
-		activeFileId := db.activeFileIds[e.GetType()]
-		db.archFiles[e.GetType()][activeFileId] = db.activeFile[e.GetType()]
-		activeFileId = activeFileId + 1
-
-		newDbFile, err := storage.NewDBFile(config.DirPath, activeFileId, config.RwMethod, config.BlockSize, e.GetType())
-		if err != nil {
-			return err
-		}
-		db.activeFile[e.GetType()] = newDbFile
-		db.activeFileIds[e.GetType()] = activeFileId
-		db.meta.ActiveWriteOff[e.GetType()] = 0
 	}
 
 	// write entry to db file.
@@ -173,9 +227,17 @@ func (db *FastDB) store(e *storage.Entry) error {
 	}
 
 	db.meta.ActiveWriteOff[e.GetType()] = db.activeFile[e.GetType()].Offset
+	if e.GetType() == String {
+		db.strHints = append(db.strHints, storage.NewHint(e, db.activeFileIds[String], db.activeFile[String].Offset-int64(e.Size())))
+	}
+
+	// the commit entries of write batches don`t modify any key.
+	if !e.IsBatchCommit() {
+		db.oracle.record(e.GetType(), e.Meta.Key)
+	}
 
 	// persist db file according to the config.
-	if config.Sync {
+	if sync {
 		if err := db.activeFile[e.GetType()].Sync(); err != nil {
 			return err
 		}
@@ -184,7 +246,51 @@ func (db *FastDB) store(e *storage.Entry) error {
 	return nil
 }
 
+// save the active db file as archived file, and open a new active db file for writing.
+func (db *FastDB) archiveActiveFile(dType DataType) error {
+	config := db.config
+	if err := db.activeFile[dType].Sync(); err != nil {
+		return err
+	}
+
+	// save the old db file as arched file.
+	activeFileId := db.activeFileIds[dType]
+	if dType == String {
+		// the db can still be opened without the hint file, so don`t fail the write.
+		if err := storage.WriteHintFile(config.DirPath, activeFileId, db.activeFile[dType].Header, db.strHints); err != nil {
+			log.Printf("rosedb: write the hint file err: %+v", err)
+		}
+		db.strHints = nil
+	}
+	db.archFiles[dType][activeFileId] = db.activeFile[dType]
+	activeFileId = activeFileId + 1
+
+	newDbFile, err := storage.NewDBFile(config.DirPath, activeFileId, config.RwMethod, config.BlockSize, dType)
+	if err != nil {
+		return err
+	}
+	db.activeFile[dType] = newDbFile
+	db.activeFileIds[dType] = activeFileId
+	db.meta.ActiveWriteOff[dType] = newDbFile.Offset
+	return nil
+}
+
+// get the db file which saves the data of the indexer.
+func (db *FastDB) getDBFile(dType DataType, fileId uint32) *storage.DBFile {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if fileId == db.activeFileIds[dType] {
+		return db.activeFile[dType]
+	}
+	return db.archFiles[dType][fileId]
+}
+
 func Open(config Config) (*FastDB, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	// create the dir path if not exists.
 	if !utils.Exist(config.DirPath) {
 		if err := os.MkdirAll(config.DirPath, os.ModePerm); err != nil {
@@ -192,6 +298,11 @@ func Open(config Config) (*FastDB, error) {
 		}
 	}
 
+	// complete the reclaim which was interrupted last time.
+	if err := recoverReclaim(config.DirPath); err != nil {
+		return nil, err
+	}
+
 	// load the db files from disk.
 	archFiles, activeFileIds, err := storage.Build(config.DirPath, config.RwMethod, config.BlockSize)
 	if err != nil {
@@ -201,6 +312,12 @@ func Open(config Config) (*FastDB, error) {
 	// set active files for writing.
 	activeFiles := make(ActiveFiles)
 	for dataType, fileId := range activeFileIds {
+		// a crash while creating the active file may leave a torn header.
+		if repaired, err := storage.RepairFileHeader(config.DirPath, fileId, dataType); err != nil {
+			return nil, err
+		} else if repaired {
+			log.Printf("rosedb: the header of the active db file %d of type %d is torn, rewrite it", fileId, dataType)
+		}
 		file, err := storage.NewDBFile(config.DirPath, fileId, config.RwMethod, config.BlockSize, dataType)
 		if err != nil {
 			return nil, err
@@ -208,11 +325,8 @@ func Open(config Config) (*FastDB, error) {
 		activeFiles[dataType] = file
 	}
 
-	// load db meta info, only active file`s write offset right now.
+	// load db meta info, the write offsets of the active files are checked when loading indexes.
 	meta := storage.LoadMeta(config.DirPath + dbMetaSaveFile)
-	for dataType, file := range activeFiles {
-		file.Offset = meta.ActiveWriteOff[dataType]
-	}
 
 	db := &FastDB{
 		activeFile:    activeFiles,
@@ -221,9 +335,14 @@ func Open(config Config) (*FastDB, error) {
 		config:        config,
 		strIndex:      NewStrIdx(),
 		hashIndex:     newHashIdx(),
+		listIndex:     newListIdx(),
+		setIndex:      newSetIdx(),
+		zsetIndex:     newZsetIdx(),
 		meta:          meta,
 
 		expires: make(Expires),
+		closed:  make(chan struct{}),
+		oracle:  newOracle(),
 	}
 	for i := 0; i < DataStructureNum; i++ {
 		db.expires[uint16(i)] = make(map[string]int64)
@@ -231,13 +350,36 @@ func Open(config Config) (*FastDB, error) {
 
 	// load indexes from db files.
 	if err := db.loadIdxFromFiles(); err != nil {
+		for _, file := range db.activeFile {
+			_ = file.Close(false)
+		}
+		for _, archFile := range db.archFiles {
+			for _, file := range archFile {
+				_ = file.Close(false)
+			}
+		}
 		return nil, err
 	}
 
+	if config.SingleReclaimInterval > 0 {
+		db.bgWg.Add(1)
+		go db.singleReclaimLoop()
+	}
+	if config.ExpireCycleInterval > 0 {
+		db.bgWg.Add(1)
+		go db.expireLoop()
+	}
+
 	return db, nil
 }
 
 func (db *FastDB) Close() error {
+	// stop the background goroutines first, they may need the lock.
+	db.closeOnce.Do(func() {
+		close(db.closed)
+	})
+	db.bgWg.Wait()
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -292,26 +434,37 @@ func (db *FastDB) buildIndex(entry *storage.Entry, idx *index.Indexer) error {
 	switch entry.GetType() {
 	case storage.String:
 		db.buildStringIndex(idx, entry)
+	case storage.List:
+		db.buildListIndex(idx, entry)
 	case storage.Hash:
 		db.buildHashIndex(idx, entry)
+	case storage.Set:
+		db.buildSetIndex(idx, entry)
+	case storage.ZSet:
+		db.buildZsetIndex(idx, entry)
 	}
 	return nil
 }
 
 // Reopen the db according to the specific config path.
 func Reopen(path string) (*FastDB, error) {
-	if exist := utils.Exist(path + configSaveFile); !exist {
-		return nil, ErrCfgNotExist
+	config, err := loadConfig(path)
+	if err != nil {
+		return nil, err
 	}
+	return Open(config)
+}
 
-	var config Config
+// load the config saved in the db dir.
+func loadConfig(path string) (config Config, err error) {
+	if exist := utils.Exist(path + configSaveFile); !exist {
+		return config, ErrCfgNotExist
+	}
 
 	b, err := ioutil.ReadFile(path + configSaveFile)
 	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(b, &config); err != nil {
-		return nil, err
+		return
 	}
-	return Open(config)
+	err = json.Unmarshal(b, &config)
+	return
 }
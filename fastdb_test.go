@@ -1,33 +1,38 @@
 package fastdb
 
-import (
-	"log"
+import "testing"
 
-	"fastdb/storage"
-)
-
-var dbPath = "/tmp/fastdb_server_data"
-
-func InitDb() *FastDB {
+// InitTestDb open a db in a fresh temp dir, so the test results do not depend on the data left by other tests.
+func InitTestDb(t *testing.T, mode DataIndexMode) *FastDB {
 	config := DefaultConfig()
-	//config.DirPath = dbPath
-	config.IdxMode = KeyOnlyMemMode
-	config.RwMethod = storage.FileIO
-	//config.BlockSize = 4 * 1024 * 1024
+	config.DirPath = t.TempDir()
+	config.IdxMode = mode
 
 	db, err := Open(config)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
+	return db
+}
 
+// CheckAndReopen run check on the db, then reopen it and run check again, returns the reopened db.
+func CheckAndReopen(t *testing.T, db *FastDB, check func(db *FastDB)) *FastDB {
+	check(db)
+	db = CloseAndReopen(t, db)
+	check(db)
 	return db
 }
 
-func ReopenDb() *FastDB {
-	db, err := Reopen(dbPath)
-	if err != nil {
-		log.Fatal(err)
+// CloseAndReopen close the db and reopen it from the same dir.
+func CloseAndReopen(t *testing.T, db *FastDB) *FastDB {
+	path := db.config.DirPath
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
 	}
 
+	db, err := Reopen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
 	return db
 }
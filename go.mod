@@ -3,6 +3,7 @@ module fastdb
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/gomodule/redigo v1.8.5
 	github.com/peterh/liner v1.2.1
 	github.com/roseduan/mmap-go v1.0.0
@@ -0,0 +1,96 @@
+package fastdb
+
+import (
+	"errors"
+	"fastdb/storage"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hintFileExists(path string, fileId uint32) bool {
+	_, err := os.Stat(path + storage.PathSeparator + fmt.Sprintf(storage.HintFileFormatName, fileId))
+	return err == nil
+}
+
+func TestFastDB_HintFile(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+	path := db.config.DirPath
+	for i := 0; i < 200; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%d", i%50)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	_ = db.StrRem([]byte("key-0"))
+	_ = db.Expire([]byte("key-1"), 100)
+	wb := db.NewWriteBatch()
+	_ = wb.Set([]byte("key-2"), []byte("batch"))
+	assert.Nil(t, wb.Commit())
+	for i := 200; i < 300; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("other-%d", i)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	assert.True(t, len(db.archFiles[String]) > 1)
+	for id := range db.archFiles[String] {
+		assert.True(t, hintFileExists(path, id))
+	}
+	assert.False(t, hintFileExists(path, db.activeFileIds[String]))
+
+	check := func(db *FastDB) {
+		assert.False(t, db.StrExists([]byte("key-0")))
+		assert.True(t, db.TTL([]byte("key-1")) > 0)
+		val, _ := db.Get([]byte("key-2"))
+		assert.Equal(t, []byte("batch"), val)
+		val, _ = db.Get([]byte("key-49"))
+		assert.Equal(t, []byte("val-199"), val)
+		assert.Equal(t, len("val-199"), db.StrLen([]byte("key-49")))
+	}
+	check(db)
+
+	// the hint file is written again when the db file is read.
+	assert.Nil(t, db.Close())
+	assert.Nil(t, storage.RemoveHintFile(path, 1))
+	db, err := Reopen(path)
+	assert.Nil(t, err)
+	check(db)
+	assert.True(t, hintFileExists(path, 1))
+	assert.Nil(t, db.Close())
+
+	// the archived db files are not read when opening, so the broken value is not found.
+	f, err := os.OpenFile(path+storage.PathSeparator+fmt.Sprintf(storage.DBFileFormatNames[String], 0), os.O_RDWR, storage.FilePerm)
+	assert.Nil(t, err)
+	_, _ = f.WriteAt([]byte("broken"), 100)
+	_ = f.Close()
+	db, err = Reopen(path)
+	assert.Nil(t, err)
+	check(db)
+	assert.Nil(t, db.Close())
+
+	// the broken db file is found without the hint file.
+	assert.Nil(t, storage.RemoveHintFile(path, 0))
+	_, err = Reopen(path)
+	assert.True(t, errors.Is(err, ErrDBFileCorrupted))
+}
+
+func TestFastDB_HintFileReclaim(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+	path := db.config.DirPath
+	for i := 0; i < 500; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%d", i%10)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	oldIds := len(db.archFiles[String])
+	assert.Nil(t, db.Reclaim())
+
+	// the hint files of the removed db files are removed too.
+	for id := uint32(0); id < uint32(oldIds); id++ {
+		_, ok := db.archFiles[String][id]
+		assert.Equal(t, ok, hintFileExists(path, id))
+	}
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+	for i := 490; i < 500; i++ {
+		val, err := db.Get([]byte(fmt.Sprintf("key-%d", i%10)))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("val-%d", i)), val)
+	}
+}
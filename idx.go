@@ -1,12 +1,16 @@
 package fastdb
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"fastdb/ds/list"
 	"fastdb/index"
 	"fastdb/storage"
 )
@@ -29,6 +33,8 @@ const (
 	StringRem
 	StringExpire
 	StringPersist
+	// StringSetEx set the value with its deadline in one entry, the deadline is saved as the timestamp.
+	StringSetEx
 )
 
 // The operations of List.
@@ -43,6 +49,7 @@ const (
 	ListLTrim
 	ListLClear
 	ListLExpire
+	ListLPersist
 )
 
 // The operations of Hash.
@@ -51,6 +58,7 @@ const (
 	HashHDel
 	HashHClear
 	HashHExpire
+	HashHPersist
 )
 
 // The operations of Set.
@@ -60,6 +68,7 @@ const (
 	SetSMove
 	SetSClear
 	SetSExpire
+	SetSPersist
 )
 
 // The operations of Sorted Set.
@@ -68,8 +77,36 @@ const (
 	ZSetZRem
 	ZSetZClear
 	ZSetZExpire
+	ZSetZPersist
 )
 
+// the expire operations of different data types.
+var expireMarks = map[DataType]uint16{
+	String: StringExpire,
+	List:   ListLExpire,
+	Hash:   HashHExpire,
+	Set:    SetSExpire,
+	ZSet:   ZSetZExpire,
+}
+
+// the persist operations of different data types.
+var persistMarks = map[DataType]uint16{
+	String: StringPersist,
+	List:   ListLPersist,
+	Hash:   HashHPersist,
+	Set:    SetSPersist,
+	ZSet:   ZSetZPersist,
+}
+
+// the operations removing a whole key of different data types.
+var clearMarks = map[DataType]uint16{
+	String: StringRem,
+	List:   ListLClear,
+	Hash:   HashHClear,
+	Set:    SetSClear,
+	ZSet:   ZSetZClear,
+}
+
 // build string indexes.
 func (db *FastDB) buildStringIndex(idx *index.Indexer, entry *storage.Entry) {
 	if db.strIndex == nil || idx == nil {
@@ -78,18 +115,28 @@ func (db *FastDB) buildStringIndex(idx *index.Indexer, entry *storage.Entry) {
 
 	switch entry.GetMark() {
 	case StringSet:
-		db.strIndex.idxList.Put(idx.Meta.Key, idx)
+		db.strIndex.put(idx.Meta.Key, idx)
+		delete(db.expires[String], string(idx.Meta.Key))
 	case StringRem:
-		db.strIndex.idxList.Remove(idx.Meta.Key)
+		db.strIndex.remove(idx.Meta.Key)
+		delete(db.expires[String], string(idx.Meta.Key))
 	case StringExpire:
 		if entry.Timestamp < uint64(time.Now().Unix()) {
-			db.strIndex.idxList.Remove(idx.Meta.Key)
+			db.strIndex.remove(idx.Meta.Key)
 		} else {
 			db.expires[String][string(idx.Meta.Key)] = int64(entry.Timestamp)
 		}
 	case StringPersist:
-		db.strIndex.idxList.Put(idx.Meta.Key, idx)
+		db.strIndex.put(idx.Meta.Key, idx)
 		delete(db.expires[String], string(idx.Meta.Key))
+	case StringSetEx:
+		if entry.Timestamp < uint64(time.Now().Unix()) {
+			db.strIndex.remove(idx.Meta.Key)
+			delete(db.expires[String], string(idx.Meta.Key))
+		} else {
+			db.strIndex.put(idx.Meta.Key, idx)
+			db.expires[String][string(idx.Meta.Key)] = int64(entry.Timestamp)
+		}
 	}
 }
 
@@ -99,6 +146,16 @@ func (db *FastDB) loadIdxFromFiles() error {
 		return nil
 	}
 
+	// the entries of write batches are applied only when the commit entry is read.
+	pending := make([]map[uint64][]batchedEntry, DataStructureNum)
+	committed := make([]map[uint64]bool, DataStructureNum)
+	errs := make([]error, DataStructureNum)
+	// the end of the written data in the active files.
+	ends := make([]int64, DataStructureNum)
+	for i := range ends {
+		ends[i] = storage.FileHeaderSize
+	}
+
 	wg := sync.WaitGroup{}
 	wg.Add(DataStructureNum)
 	for dataType := 0; dataType < DataStructureNum; dataType++ {
@@ -107,6 +164,9 @@ func (db *FastDB) loadIdxFromFiles() error {
 				wg.Done()
 			}()
 
+			pending[dType] = make(map[uint64][]batchedEntry)
+			committed[dType] = make(map[uint64]bool)
+
 			// archived files
 			var fileIds []int
 			dbFile := make(map[uint32]*storage.DBFile)
@@ -119,39 +179,193 @@ func (db *FastDB) loadIdxFromFiles() error {
 			dbFile[db.activeFileIds[dType]] = db.activeFile[dType]
 			fileIds = append(fileIds, int(db.activeFileIds[dType]))
 
+			// apply the entry read from the db files to the indexes.
+			load := func(e *storage.Entry, idx *index.Indexer) error {
+				if idx.FileId == db.activeFileIds[dType] {
+					ends[dType] = idx.Offset + int64(idx.EntrySize)
+				}
+				if batchId, ok := e.BatchId(); ok {
+					if !e.IsBatchCommit() {
+						pending[dType][batchId] = append(pending[dType][batchId], batchedEntry{e, idx})
+						return nil
+					}
+					committed[dType][batchId] = true
+					for _, b := range pending[dType][batchId] {
+						if err := db.buildIndex(b.entry, b.idx); err != nil {
+							return err
+						}
+					}
+					delete(pending[dType], batchId)
+					return nil
+				}
+				return db.buildIndex(e, idx)
+			}
+
 			// load the db files in a specified order.
 			sort.Ints(fileIds)
 			for i := 0; i < len(fileIds); i++ {
 				fid := uint32(fileIds[i])
 				df := dbFile[fid]
-				var offset int64 = 0
+				archived := fid != db.activeFileIds[dType]
 
-				for offset <= db.config.BlockSize {
-					if e, err := df.Read(offset); err == nil {
-						idx := &index.Indexer{
-							Meta:      e.Meta,
-							FileId:    fid,
-							EntrySize: e.Size(),
-							Offset:    offset,
-						}
-						offset += int64(e.Size())
-
-						if len(e.Meta.Key) > 0 {
-							if err := db.buildIndex(e, idx); err != nil {
-								log.Fatalf("a fatal err occurred, the db can not open.[%+v]", err)
+				// the String indexes of the archived files are built from the hint files if the values are not needed.
+				useHint := dType == String && archived && db.config.IdxMode == KeyOnlyMemMode
+				if useHint {
+					if hints, err := storage.ReadHintFile(db.config.DirPath, fid, df.Header); err == nil {
+						for _, h := range hints {
+							e := h.Entry()
+							idx := &index.Indexer{Meta: e.Meta, FileId: fid, EntrySize: h.EntrySize, Offset: h.Offset}
+							if errs[dType] = load(e, idx); errs[dType] != nil {
+								return
 							}
 						}
-					} else {
-						if err == io.EOF {
-							break
+						continue
+					}
+				}
+
+				var hints []*storage.Hint
+				var offset int64 = storage.FileHeaderSize
+				for offset <= db.config.BlockSize {
+					e, err := df.Read(offset)
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						if errs[dType] = db.recoverTornWrite(dType, fid, offset, err); errs[dType] != nil {
+							return
 						}
-						log.Fatalf("a fatal err occurred, the db can not open.[%+v]", err)
+						break
+					}
+
+					idx := &index.Indexer{
+						Meta:      e.Meta,
+						FileId:    fid,
+						EntrySize: e.Size(),
+						Offset:    offset,
+					}
+					offset += int64(e.Size())
+
+					if len(e.Meta.Key) == 0 {
+						continue
+					}
+					if dType == String {
+						hints = append(hints, storage.NewHint(e, fid, idx.Offset))
+					}
+					if errs[dType] = load(e, idx); errs[dType] != nil {
+						return
+					}
+				}
+
+				// the hints of the active file are saved when it is archived.
+				if dType == String && !archived {
+					db.strHints = hints
+				}
+				if useHint {
+					if err := storage.WriteHintFile(db.config.DirPath, fid, df.Header, hints); err != nil {
+						log.Printf("rosedb: write the hint file err: %+v", err)
 					}
 				}
 			}
 		}(uint16(dataType))
 	}
 	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	db.reconcileWriteOff(ends)
+	return db.recoverBatches(pending, committed)
+}
+
+// the write offsets in meta are saved only when closing the db, they are stale after a crash,
+// so the active files are always written after the end of the data found when loading them.
+func (db *FastDB) reconcileWriteOff(ends []int64) {
+	for dType := 0; dType < DataStructureNum; dType++ {
+		file := db.activeFile[uint16(dType)]
+		if off, ok := db.meta.ActiveWriteOff[uint16(dType)]; ok && off != ends[dType] {
+			log.Printf("rosedb: the write offset of db file %s in meta is %d, but the data ends at %d, use the end of data.",
+				fmt.Sprintf(storage.DBFileFormatNames[uint16(dType)], db.activeFileIds[uint16(dType)]), off, ends[dType])
+		}
+		file.Offset = ends[dType]
+		db.meta.ActiveWriteOff[uint16(dType)] = ends[dType]
+	}
+}
+
+// a broken entry at the tail of the active file is a torn write caused by a crash, it was never acknowledged, so truncate it.
+// Any other broken entry means the db file is corrupted, returns ErrDBFileCorrupted.
+func (db *FastDB) recoverTornWrite(dType DataType, fileId uint32, offset int64, readErr error) error {
+	df := db.getDBFile(dType, fileId)
+	if fileId == db.activeFileIds[dType] {
+		tail, err := df.IsTail(offset)
+		if err != nil {
+			return err
+		}
+		if tail {
+			dropped, err := df.Truncate(offset)
+			if err != nil {
+				return err
+			}
+			log.Printf("rosedb: truncated the torn write of db file %s at offset %d, %d bytes dropped.[%+v]",
+				fmt.Sprintf(storage.DBFileFormatNames[dType], fileId), offset, dropped, readErr)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w, file: %s, offset: %d.[%+v]",
+		ErrDBFileCorrupted, fmt.Sprintf(storage.DBFileFormatNames[dType], fileId), offset, readErr)
+}
+
+// an entry of a write batch which is not committed yet.
+type batchedEntry struct {
+	entry *storage.Entry
+	idx   *index.Indexer
+}
+
+// a batch may be interrupted after its commit entry is written to some of the data types,
+// it is committed, so apply the remaining entries and write the missing commit entries.
+// The batches without any commit entry are discarded.
+func (db *FastDB) recoverBatches(pending []map[uint64][]batchedEntry, committed []map[uint64]bool) error {
+	isCommitted := func(batchId uint64) bool {
+		for dType := 0; dType < DataStructureNum; dType++ {
+			if committed[dType][batchId] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for dType := 0; dType < DataStructureNum; dType++ {
+		for batchId := range committed[dType] {
+			if batchId > db.lastBatchId {
+				db.lastBatchId = batchId
+			}
+		}
+
+		var batchIds []uint64
+		for batchId := range pending[dType] {
+			if batchId > db.lastBatchId {
+				db.lastBatchId = batchId
+			}
+			if isCommitted(batchId) {
+				batchIds = append(batchIds, batchId)
+			}
+		}
+		sort.Slice(batchIds, func(i, j int) bool {
+			return batchIds[i] < batchIds[j]
+		})
+
+		for _, batchId := range batchIds {
+			for _, b := range pending[dType][batchId] {
+				if err := db.buildIndex(b.entry, b.idx); err != nil {
+					return err
+				}
+			}
+			if err := db.store(storage.NewBatchCommitEntry(uint16(dType), batchId)); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -164,16 +378,139 @@ func (db *FastDB) buildHashIndex(idx *index.Indexer, entry *storage.Entry) {
 	key := string(idx.Meta.Key)
 	switch entry.GetMark() {
 	case HashHSet:
-		db.hashIndex.indexes.HSet(key, string(idx.Meta.Extra), idx.Meta.Value)
+		db.hashIndex.indexes.HSet(key, string(idx.Meta.Extra), db.hashIdxVal(idx.Meta.Value, idx.FileId, idx.Offset))
 	case HashHDel:
 		db.hashIndex.indexes.HDel(key, string(idx.Meta.Extra))
 	case HashHClear:
 		db.hashIndex.indexes.HClear(key)
+		delete(db.expires[Hash], key)
 	case HashHExpire:
 		if entry.Timestamp < uint64(time.Now().Unix()) {
 			db.hashIndex.indexes.HClear(key)
+			delete(db.expires[Hash], key)
 		} else {
 			db.expires[Hash][key] = int64(entry.Timestamp)
 		}
+	case HashHPersist:
+		delete(db.expires[Hash], key)
+	}
+}
+
+// build list indexes.
+func (db *FastDB) buildListIndex(idx *index.Indexer, entry *storage.Entry) {
+	if db.listIndex == nil || idx == nil {
+		return
+	}
+
+	key := string(idx.Meta.Key)
+	switch entry.GetMark() {
+	case ListLPush:
+		db.listIndex.indexes.LPush(key, idx.Meta.Value)
+	case ListLPop:
+		db.listIndex.indexes.LPop(key)
+	case ListRPush:
+		db.listIndex.indexes.RPush(key, idx.Meta.Value)
+	case ListRPop:
+		db.listIndex.indexes.RPop(key)
+	case ListLRem:
+		if count, err := strconv.Atoi(string(idx.Meta.Extra)); err == nil {
+			db.listIndex.indexes.LRem(key, idx.Meta.Value, count)
+		}
+	case ListLInsert:
+		extra := string(idx.Meta.Extra)
+		s := strings.Split(extra, ExtraSeparator)
+		if len(s) == 2 {
+			pivot := []byte(s[0])
+			if opt, err := strconv.Atoi(s[1]); err == nil {
+				db.listIndex.indexes.LInsert(key, list.InsertOption(opt), pivot, idx.Meta.Value)
+			}
+		}
+	case ListLSet:
+		if i, err := strconv.Atoi(string(idx.Meta.Extra)); err == nil {
+			db.listIndex.indexes.LSet(key, i, idx.Meta.Value)
+		}
+	case ListLTrim:
+		extra := string(idx.Meta.Extra)
+		s := strings.Split(extra, ExtraSeparator)
+		if len(s) == 2 {
+			start, _ := strconv.Atoi(s[0])
+			end, _ := strconv.Atoi(s[1])
+			db.listIndex.indexes.LTrim(key, start, end)
+		}
+	case ListLClear:
+		db.listIndex.indexes.LClear(key)
+		delete(db.expires[List], key)
+	case ListLExpire:
+		if entry.Timestamp < uint64(time.Now().Unix()) {
+			db.listIndex.indexes.LClear(key)
+			delete(db.expires[List], key)
+		} else {
+			db.expires[List][key] = int64(entry.Timestamp)
+		}
+	case ListLPersist:
+		delete(db.expires[List], key)
+	}
+}
+
+// build set indexes.
+func (db *FastDB) buildSetIndex(idx *index.Indexer, entry *storage.Entry) {
+	if db.setIndex == nil || idx == nil {
+		return
+	}
+
+	key := string(idx.Meta.Key)
+	switch entry.GetMark() {
+	case SetSAdd:
+		db.setIndex.indexes.SAdd(key, idx.Meta.Value)
+	case SetSRem:
+		db.setIndex.indexes.SRem(key, idx.Meta.Value)
+	case SetSMove:
+		// the destination key is saved in the extra info.
+		if idx.Meta.Extra == nil {
+			break
+		}
+		db.setIndex.indexes.SMove(key, string(idx.Meta.Extra), idx.Meta.Value)
+	case SetSClear:
+		db.setIndex.indexes.SClear(key)
+		delete(db.expires[Set], key)
+	case SetSExpire:
+		if entry.Timestamp < uint64(time.Now().Unix()) {
+			db.setIndex.indexes.SClear(key)
+			delete(db.expires[Set], key)
+		} else {
+			db.expires[Set][key] = int64(entry.Timestamp)
+		}
+	case SetSPersist:
+		delete(db.expires[Set], key)
+	}
+}
+
+// build sorted set indexes.
+func (db *FastDB) buildZsetIndex(idx *index.Indexer, entry *storage.Entry) {
+	if db.zsetIndex == nil || idx == nil {
+		return
+	}
+
+	key := string(idx.Meta.Key)
+	switch entry.GetMark() {
+	case ZSetZAdd:
+		// the score is saved in the extra info.
+		if score, ok := decodeScore(idx.Meta.Extra); ok {
+			db.zsetIndex.indexes.ZAdd(key, score, string(idx.Meta.Value))
+		}
+	case ZSetZRem:
+		db.zsetIndex.indexes.ZRem(key, string(idx.Meta.Value))
+	case ZSetZClear:
+		db.zsetIndex.indexes.ZClear(key)
+		delete(db.expires[ZSet], key)
+	case ZSetZExpire:
+		if entry.Timestamp < uint64(time.Now().Unix()) {
+			db.zsetIndex.indexes.ZClear(key)
+			delete(db.expires[ZSet], key)
+		} else {
+			db.expires[ZSet][key] = int64(entry.Timestamp)
+		}
+	case ZSetZPersist:
+		delete(db.expires[ZSet], key)
 	}
 }
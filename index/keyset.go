@@ -0,0 +1,74 @@
+package index
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// MaxKeySetCursor the max cursor of KeySet, the cursors are in [0, MaxKeySetCursor], so a few high bits can be used by the caller.
+const MaxKeySetCursor = 1 << 60
+
+// KeySet the keys ordered by their hash, it is scanned with an integer cursor like the SCAN command of redis.
+// The cursor is the position of the last key visited, so nothing is saved between the scans,
+// and a key existing during the whole scanning is always visited, the keys added or removed may be visited or not.
+type KeySet struct {
+	skl *SkipList
+}
+
+// NewKeySet create a new key set.
+func NewKeySet() *KeySet {
+	return &KeySet{skl: NewSkipList()}
+}
+
+// Add add the key to the set, nothing is done if it exists.
+func (s *KeySet) Add(key string) {
+	k := keySetKey(key)
+	if !s.skl.Exist(k) {
+		s.skl.Put(k, nil)
+	}
+}
+
+// Remove remove the key from the set.
+func (s *KeySet) Remove(key string) {
+	s.skl.Remove(keySetKey(key))
+}
+
+// Scan returns at least count keys after the cursor, fewer keys are returned only at the end, 0 means the beginning.
+// The keys at the same position are always returned together, so more keys may be returned.
+// The next cursor is the position of the last key returned, it is 0 if there are no more keys.
+func (s *KeySet) Scan(cursor uint64, count int) (keys []string, next uint64) {
+	if cursor >= MaxKeySetCursor {
+		return nil, 0
+	}
+
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, cursor+1)
+	e := s.skl.Seek(start)
+	for ; e != nil; e = e.Next() {
+		pos := binary.BigEndian.Uint64(e.Key())
+		if len(keys) >= count && pos != next {
+			break
+		}
+		keys = append(keys, string(e.Key()[8:]))
+		next = pos
+	}
+	if e == nil {
+		next = 0
+	}
+	return
+}
+
+// the position of the key in the set, it is in [1, MaxKeySetCursor].
+func keySetPos(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()>>4 + 1
+}
+
+// the key in the skip list, it is ordered by the position first.
+func keySetKey(key string) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, keySetPos(key))
+	copy(k[8:], key)
+	return k
+}
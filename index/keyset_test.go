@@ -0,0 +1,61 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySet_Scan(t *testing.T) {
+	set := NewKeySet()
+	keys, next := set.Scan(0, 10)
+	assert.Nil(t, keys)
+	assert.Zero(t, next)
+
+	for i := 0; i < 100; i++ {
+		set.Add(fmt.Sprintf("key-%d", i))
+	}
+	set.Add("key-0")
+	set.Remove("key-1")
+	set.Remove("not-exist")
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		keys, cursor = set.Scan(cursor, 7)
+		assert.True(t, cursor <= MaxKeySetCursor)
+		for _, k := range keys {
+			seen[k]++
+		}
+		if cursor == 0 {
+			break
+		}
+		assert.Equal(t, 7, len(keys))
+	}
+	assert.Equal(t, 99, len(seen))
+	assert.Zero(t, seen["key-1"])
+	for k, n := range seen {
+		assert.Equal(t, 1, n, k)
+	}
+
+	keys, next = set.Scan(MaxKeySetCursor, 10)
+	assert.Nil(t, keys)
+	assert.Zero(t, next)
+}
+
+func TestKeySet_ScanSamePos(t *testing.T) {
+	set := NewKeySet()
+	// the keys at the same position are returned together.
+	for _, k := range []string{"a", "b", "c"} {
+		set.skl.Put(append([]byte{0, 0, 0, 0, 0, 0, 0, 5}, k...), nil)
+	}
+	set.skl.Put(append([]byte{0, 0, 0, 0, 0, 0, 0, 9}, "d"...), nil)
+
+	keys, next := set.Scan(0, 1)
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, uint64(5), next)
+	keys, next = set.Scan(next, 1)
+	assert.Equal(t, []string{"d"}, keys)
+	assert.Zero(t, next)
+}
@@ -30,6 +30,10 @@ type (
 		Node
 		key   []byte
 		value interface{}
+		// prev the previous element in the first-level index, for traversing backward.
+		prev *Element
+		// removed is set when the element is removed from the skip list.
+		removed bool
 	}
 
 	// SkipList define the skip list.
@@ -37,6 +41,7 @@ type (
 		Node
 		maxLevel       int
 		Len            int
+		tail           *Element
 		randSource     rand.Source
 		probability    float64
 		probTable      []float64
@@ -77,6 +82,16 @@ func (e *Element) Next() *Element {
 	return e.next[0]
 }
 
+// Prev the previous element in the first-level index, returns nil if it is the first element.
+func (e *Element) Prev() *Element {
+	return e.prev
+}
+
+// Removed reports whether the element has been removed from the skip list, its Next and Prev can`t be used then.
+func (e *Element) Removed() bool {
+	return e.removed
+}
+
 // Front first element.
 // Get the head element of skl, and get all data by traversing backward.
 //	e := list.Front()
@@ -87,6 +102,16 @@ func (t *SkipList) Front() *Element {
 	return t.next[0]
 }
 
+// Back last element.
+// Get the tail element of skl, and get all data by traversing forward.
+//	e := list.Back()
+//	for p := e; p != nil; p = p.Prev() {
+//		//do something with Element p
+//	}
+func (t *SkipList) Back() *Element {
+	return t.tail
+}
+
 // Put an element into skip list, replace the value if key already exists.
 func (t *SkipList) Put(key []byte, value interface{}) *Element {
 	var element *Element
@@ -105,6 +130,15 @@ func (t *SkipList) Put(key []byte, value interface{}) *Element {
 		value: value,
 	}
 
+	// the element is inserted before the old next element of the first level.
+	if next := prev[0].next[0]; next != nil {
+		element.prev = next.prev
+		next.prev = element
+	} else {
+		element.prev = t.tail
+		t.tail = element
+	}
+
 	for i := range element.next {
 		element.next[i] = prev[i].next[i]
 		prev[i].next[i] = element
@@ -148,6 +182,12 @@ func (t *SkipList) Remove(key []byte) *Element {
 		for k, v := range element.next {
 			prev[k].next[k] = v
 		}
+		if next := element.next[0]; next != nil {
+			next.prev = element.prev
+		} else {
+			t.tail = element.prev
+		}
+		element.removed = true
 
 		t.Len--
 		return element
@@ -187,22 +227,28 @@ func (t *SkipList) backNodes(key []byte) []*Node {
 
 // FindPrefix find the first element that matches the prefix.
 func (t *SkipList) FindPrefix(prefix []byte) *Element {
+	next := t.Seek(prefix)
+	if next == nil {
+		next = t.Front()
+	}
+
+	return next
+}
+
+// Seek find the first element whose key is not less than the key, nil will be returned if there is no such element.
+func (t *SkipList) Seek(key []byte) *Element {
 	var prev = &t.Node
 	var next *Element
 
 	for i := t.maxLevel - 1; i >= 0; i-- {
 		next = prev.next[i]
 
-		for next != nil && bytes.Compare(prefix, next.key) > 0 {
+		for next != nil && bytes.Compare(key, next.key) > 0 {
 			prev = &next.Node
 			next = next.next[i]
 		}
 	}
 
-	if next == nil {
-		next = t.Front()
-	}
-
 	return next
 }
 
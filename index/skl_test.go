@@ -71,7 +71,14 @@ func TestSkipList_Remove(t *testing.T) {
 	list.Put([]byte("dc"), 123)
 	list.Put([]byte("ac"), val)
 
+	e := list.Get([]byte("dc"))
+	if e.Removed() {
+		t.Error("the element is not removed yet")
+	}
 	list.Remove([]byte("dc"))
+	if !e.Removed() {
+		t.Error("the element should be marked as removed")
+	}
 	list.Remove([]byte("ec"))
 	list.Remove([]byte("ac"))
 }
@@ -152,6 +159,27 @@ func TestSkipList_Front(t *testing.T) {
 	_ = skl.Front()
 }
 
+func TestSkipList_Back(t *testing.T) {
+	skl := NewSkipList()
+	if skl.Back() != nil {
+		t.Error("expected nil back of empty skl")
+	}
+
+	for _, k := range []string{"c", "a", "e", "b", "d"} {
+		skl.Put([]byte(k), k)
+	}
+	skl.Remove([]byte("e"))
+	skl.Remove([]byte("b"))
+
+	var keys string
+	for p := skl.Back(); p != nil; p = p.Prev() {
+		keys += string(p.Key())
+	}
+	if keys != "dca" {
+		t.Errorf("expected dca, got %s", keys)
+	}
+}
+
 func TestSkipList_PrefixScan(t *testing.T) {
 	list := NewSkipList()
 	list.Put([]byte("acccbf"), 132)
@@ -168,3 +196,29 @@ func TestSkipList_PrefixScan(t *testing.T) {
 	e3 := list.FindPrefix([]byte("accc"))
 	t.Logf("%+v", e3)
 }
+
+func TestSkipList_Seek(t *testing.T) {
+	list := NewSkipList()
+	for _, k := range []string{"acccbf", "acceew", "acadef", "accdef"} {
+		list.Put([]byte(k), k)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", "acadef"},
+		{"acc", "acccbf"},
+		{"accdef", "accdef"},
+		{"acce", "acceew"},
+	}
+	for _, tt := range tests {
+		if e := list.Seek([]byte(tt.key)); e == nil || string(e.Key()) != tt.want {
+			t.Errorf("seek %s, expected %s, got %+v", tt.key, tt.want, e)
+		}
+	}
+
+	if e := list.Seek([]byte("eee")); e != nil {
+		t.Errorf("expected nil, got %s", e.Key())
+	}
+}
@@ -0,0 +1,350 @@
+package fastdb
+
+import (
+	"bytes"
+	"fastdb/index"
+	"time"
+)
+
+// IteratorOptions the options of the string iterator.
+type IteratorOptions struct {
+	// Prefix only the keys with the prefix will be iterated.
+	Prefix []byte
+
+	// Reverse iterate the keys from the biggest to the smallest.
+	Reverse bool
+}
+
+// Iterator iterate the string keys in order without loading all of them into memory.
+// It sees the strings as of its creation: the read lock of strings is only held while moving the iterator,
+// so Set and StrRem can be called during the iteration, but the strings are saved in the iterator before they are modified,
+// and the following writes are not visible to it. The keys expired at its creation are skipped.
+// The iterator must be closed after used, otherwise the strings modified are kept in memory.
+//
+//	it := db.NewIterator(IteratorOptions{Prefix: []byte("user:")})
+//	defer it.Close()
+//	for it.Rewind(); it.Valid(); it.Next() {
+//		//do something with it.Key() and it.Value()
+//	}
+type Iterator struct {
+	db        *FastDB
+	opts      IteratorOptions
+	prefixEnd []byte
+	snap      *strSnapshot
+	key       []byte
+	value     []byte
+	valid     bool
+	closed    bool
+	err       error
+
+	// the next elements of the strings index and the snapshot in the direction of the last move.
+	forward      bool
+	live         *index.Element
+	saved        *index.Element
+	savedVersion uint64
+}
+
+// strSnapshot keeps the state of the strings modified after an iterator is created, the state before the first modification is saved.
+type strSnapshot struct {
+	createdAt int64
+
+	// the value of an element is a *savedStr, nil if the key did not exist or was expired.
+	saved *index.SkipList
+
+	// increased by every key saved, the iterator seeks the saved keys again if it changes.
+	version uint64
+}
+
+type savedStr struct {
+	value []byte
+	err   error
+}
+
+// NewIterator create a new iterator of strings, it is positioned at the first key.
+func (db *FastDB) NewIterator(opts IteratorOptions) *Iterator {
+	it := &Iterator{
+		db:        db,
+		opts:      opts,
+		prefixEnd: prefixEnd(opts.Prefix),
+		snap: &strSnapshot{
+			createdAt: time.Now().Unix(),
+			saved:     index.NewSkipList(),
+		},
+	}
+
+	db.strIndex.mu.Lock()
+	db.strIndex.snapshots[it.snap] = struct{}{}
+	db.strIndex.mu.Unlock()
+
+	it.Rewind()
+	return it
+}
+
+// Rewind move the iterator to the first key, or the last key in reverse order.
+func (it *Iterator) Rewind() {
+	if it.opts.Reverse {
+		// nil means the end of the index.
+		it.seek(it.prefixEnd, false, false)
+		return
+	}
+	it.seek(it.opts.Prefix, true, true)
+}
+
+// Seek move the iterator to the first key which is not less than key, or not greater than key in reverse order.
+func (it *Iterator) Seek(key []byte) {
+	if it.opts.Reverse {
+		if it.prefixEnd != nil && bytes.Compare(key, it.prefixEnd) >= 0 {
+			it.seek(it.prefixEnd, false, false)
+		} else {
+			it.seek(key, false, true)
+		}
+		return
+	}
+
+	if bytes.Compare(key, it.opts.Prefix) < 0 {
+		key = it.opts.Prefix
+	}
+	it.seek(key, true, true)
+}
+
+// Next move the iterator to the next key in the order of iteration.
+func (it *Iterator) Next() {
+	if !it.Valid() {
+		return
+	}
+	it.move(!it.opts.Reverse)
+}
+
+// Prev move the iterator to the previous key in the order of iteration.
+func (it *Iterator) Prev() {
+	if !it.Valid() {
+		return
+	}
+	it.move(it.opts.Reverse)
+}
+
+// Valid returns whether the iterator is positioned at a key.
+func (it *Iterator) Valid() bool {
+	return !it.closed && it.valid
+}
+
+// Key returns the key of the current position.
+func (it *Iterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.key
+}
+
+// Value returns the value of the current position.
+func (it *Iterator) Value() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.value
+}
+
+// Err returns the error occurred while reading the value from db file.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close close the iterator, it is invalid after closed.
+func (it *Iterator) Close() {
+	if it.closed {
+		return
+	}
+	it.closed = true
+	it.key, it.value = nil, nil
+	it.live, it.saved = nil, nil
+
+	it.db.strIndex.mu.Lock()
+	delete(it.db.strIndex.snapshots, it.snap)
+	it.db.strIndex.mu.Unlock()
+}
+
+// find the first key from key in the direction, then move to the first visible key.
+func (it *Iterator) seek(key []byte, forward, inclusive bool) {
+	it.valid = false
+	if it.closed || it.err != nil {
+		return
+	}
+
+	db := it.db
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	it.forward = forward
+	it.live = seekElement(db.strIndex.idxList, key, forward, inclusive)
+	it.saved = seekElement(it.snap.saved, key, forward, inclusive)
+	it.savedVersion = it.snap.version
+	it.advance()
+}
+
+// move to the adjacent key of the current key.
+// The elements found by the last move are still used, unless the element is removed from the index,
+// or some keys are saved in the snapshot, or the direction is changed.
+func (it *Iterator) move(forward bool) {
+	if forward != it.forward {
+		it.seek(it.key, forward, false)
+		return
+	}
+
+	it.valid = false
+	if it.closed || it.err != nil {
+		return
+	}
+
+	db := it.db
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	if it.live != nil && it.live.Removed() {
+		it.live = seekElement(db.strIndex.idxList, it.key, forward, false)
+	}
+	if it.savedVersion != it.snap.version {
+		it.saved = seekElement(it.snap.saved, it.key, forward, false)
+		it.savedVersion = it.snap.version
+	}
+	it.advance()
+}
+
+// move to the first visible key from the next elements, the caller should hold the read lock of strings.
+// The state saved in the snapshot is used if the key has been modified, otherwise the state in the index is used.
+func (it *Iterator) advance() {
+	db := it.db
+	for it.live != nil || it.saved != nil {
+		var key, val []byte
+		var exist bool
+		if it.live == nil || (it.saved != nil && it.before(it.saved.Key(), it.live.Key())) {
+			key = it.saved.Key()
+			if s := it.saved.Value().(*savedStr); s != nil {
+				val, exist, it.err = s.value, true, s.err
+			}
+			if it.live != nil && bytes.Equal(it.live.Key(), key) {
+				it.live = it.step(it.live)
+			}
+			it.saved = it.step(it.saved)
+		} else {
+			key = it.live.Key()
+			if deadline, ok := db.expires[String][string(key)]; !ok || it.snap.createdAt <= deadline {
+				val, it.err = db.getIdxVal(it.live.Value().(*index.Indexer))
+				exist = true
+			}
+			it.live = it.step(it.live)
+		}
+
+		if it.err != nil || !bytes.HasPrefix(key, it.opts.Prefix) {
+			return
+		}
+		if exist {
+			it.key = append([]byte(nil), key...)
+			it.value = append([]byte(nil), val...)
+			it.valid = true
+			return
+		}
+	}
+}
+
+// check if key a is before key b in the direction of the last move, the same key is treated as before.
+func (it *Iterator) before(a, b []byte) bool {
+	if it.forward {
+		return bytes.Compare(a, b) <= 0
+	}
+	return bytes.Compare(a, b) >= 0
+}
+
+func (it *Iterator) step(e *index.Element) *index.Element {
+	if it.forward {
+		return e.Next()
+	}
+	return e.Prev()
+}
+
+// save the state of key in the snapshots of the running iterators before it is modified.
+// It is only saved at the first modification, and the caller should hold the write lock of strings.
+func (db *FastDB) saveStrSnapshots(key []byte) {
+	if len(db.strIndex.snapshots) == 0 {
+		return
+	}
+
+	var s *savedStr
+	var deadline int64
+	var ttl, loaded bool
+	for snap := range db.strIndex.snapshots {
+		if snap.saved.Exist(key) {
+			continue
+		}
+		if !loaded {
+			if e := db.strIndex.idxList.Get(key); e != nil {
+				val, err := db.getIdxVal(e.Value().(*index.Indexer))
+				s = &savedStr{value: val, err: err}
+			}
+			deadline, ttl = db.expires[String][string(key)]
+			loaded = true
+		}
+
+		k := append([]byte(nil), key...)
+		if s != nil && (!ttl || snap.createdAt <= deadline) {
+			snap.saved.Put(k, s)
+		} else {
+			snap.saved.Put(k, (*savedStr)(nil))
+		}
+		snap.version++
+	}
+}
+
+// find the first element from key in the direction, nil key means the beginning of the direction.
+func seekElement(skl *index.SkipList, key []byte, forward, inclusive bool) *index.Element {
+	if !forward && key == nil {
+		return skl.Back()
+	}
+
+	switch {
+	case forward && inclusive:
+		return skl.Seek(key)
+	case forward:
+		e := skl.Seek(key)
+		if e != nil && bytes.Equal(e.Key(), key) {
+			e = e.Next()
+		}
+		return e
+	case inclusive:
+		return seekLE(skl, key)
+	default:
+		return seekLT(skl, key)
+	}
+}
+
+// find the last element whose key is not greater than key.
+func seekLE(skl *index.SkipList, key []byte) *index.Element {
+	e := skl.Seek(key)
+	if e == nil {
+		return skl.Back()
+	}
+	if !bytes.Equal(e.Key(), key) {
+		return e.Prev()
+	}
+	return e
+}
+
+// find the last element whose key is less than key.
+func seekLT(skl *index.SkipList, key []byte) *index.Element {
+	e := skl.Seek(key)
+	if e == nil {
+		return skl.Back()
+	}
+	return e.Prev()
+}
+
+// returns the smallest key which is greater than all the keys with the prefix, nil means there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package fastdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectKeys(it *Iterator) (keys []string) {
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	return
+}
+
+func TestIterator(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := InitTestDb(t, mode)
+
+		for _, k := range []string{"a", "b:1", "b:2", "b:3", "c"} {
+			_ = db.Set([]byte(k), []byte("val-"+k))
+		}
+		db.strIndex.mu.Lock()
+		_ = db.expire([]byte("b:2"), time.Now().Unix()-1, String)
+		db.strIndex.mu.Unlock()
+
+		it := db.NewIterator(IteratorOptions{})
+		assert.Equal(t, []byte("val-a"), it.Value())
+		assert.Equal(t, []string{"a", "b:1", "b:3", "c"}, collectKeys(it))
+		it.Close()
+
+		it = db.NewIterator(IteratorOptions{Reverse: true})
+		assert.Equal(t, []string{"c", "b:3", "b:1", "a"}, collectKeys(it))
+
+		it = db.NewIterator(IteratorOptions{Prefix: []byte("b:")})
+		assert.Equal(t, []string{"b:1", "b:3"}, collectKeys(it))
+
+		it = db.NewIterator(IteratorOptions{Prefix: []byte("b:"), Reverse: true})
+		assert.Equal(t, []string{"b:3", "b:1"}, collectKeys(it))
+
+		// seek and prev.
+		it = db.NewIterator(IteratorOptions{})
+		it.Seek([]byte("b"))
+		assert.Equal(t, []byte("b:1"), it.Key())
+		it.Prev()
+		assert.Equal(t, []byte("a"), it.Key())
+		it.Prev()
+		assert.False(t, it.Valid())
+
+		it = db.NewIterator(IteratorOptions{Reverse: true})
+		it.Seek([]byte("b:2"))
+		assert.Equal(t, []byte("b:1"), it.Key())
+		it.Prev()
+		assert.Equal(t, []byte("b:3"), it.Key())
+
+		it = db.NewIterator(IteratorOptions{Prefix: []byte("b:"), Reverse: true})
+		it.Seek([]byte("z"))
+		assert.Equal(t, []byte("b:3"), it.Key())
+		it.Close()
+		assert.False(t, it.Valid())
+		assert.Nil(t, it.Key())
+
+		it = db.NewIterator(IteratorOptions{Prefix: []byte("d")})
+		assert.False(t, it.Valid())
+		assert.Nil(t, it.Err())
+
+		_ = db.Close()
+	}
+}
+
+func TestIterator_ConcurrentWrites(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%04d", i)), []byte("val"))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			key := []byte(fmt.Sprintf("key-%04d", i))
+			if i%2 == 0 {
+				_ = db.StrRem(key)
+			} else {
+				_ = db.Set(key, []byte("new"))
+			}
+		}
+	}()
+
+	it := db.NewIterator(IteratorOptions{})
+	defer it.Close()
+
+	// all the keys are seen as of the creation of the iterator.
+	var count int
+	var prev []byte
+	for ; it.Valid(); it.Next() {
+		assert.True(t, prev == nil || string(prev) < string(it.Key()))
+		assert.Equal(t, "val", string(it.Value()))
+		prev = it.Key()
+		count++
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 1000, count)
+	<-done
+}
+
+func TestIterator_Snapshot(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := InitTestDb(t, mode)
+
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			_ = db.Set([]byte(k), []byte("val-"+k))
+		}
+		it := db.NewIterator(IteratorOptions{})
+		assert.Equal(t, []byte("a"), it.Key())
+
+		// the writes after creating the iterator are not visible.
+		_ = db.Set([]byte("b"), []byte("new-b"))
+		_ = db.Set([]byte("b"), []byte("newer-b"))
+		_ = db.StrRem([]byte("c"))
+		_ = db.Set([]byte("cc"), []byte("val-cc"))
+		_ = db.Expire([]byte("d"), 1)
+		db.strIndex.mu.Lock()
+		_ = db.expire([]byte("d"), time.Now().Unix()-1, String)
+		db.strIndex.mu.Unlock()
+		wb := db.NewWriteBatch()
+		_ = wb.StrRem([]byte("e"))
+		_ = wb.Set([]byte("f"), []byte("val-f"))
+		assert.Nil(t, wb.Commit())
+
+		var kvs []string
+		for ; it.Valid(); it.Next() {
+			kvs = append(kvs, string(it.Key())+"="+string(it.Value()))
+		}
+		assert.Equal(t, []string{"a=val-a", "b=val-b", "c=val-c", "d=val-d", "e=val-e"}, kvs)
+
+		it.Seek([]byte("c"))
+		assert.Equal(t, []byte("c"), it.Key())
+		it.Prev()
+		assert.Equal(t, []byte("val-b"), it.Value())
+		it.Next()
+		it.Next()
+		assert.Equal(t, []byte("d"), it.Key())
+		it.Close()
+		assert.Equal(t, 0, len(db.strIndex.snapshots))
+
+		// a new iterator sees the latest strings.
+		it = db.NewIterator(IteratorOptions{Reverse: true})
+		kvs = nil
+		for ; it.Valid(); it.Next() {
+			kvs = append(kvs, string(it.Key())+"="+string(it.Value()))
+		}
+		assert.Equal(t, []string{"f=val-f", "cc=val-cc", "b=newer-b", "a=val-a"}, kvs)
+		it.Close()
+		_ = db.Close()
+	}
+}
@@ -0,0 +1,309 @@
+package fastdb
+
+import (
+	"fastdb/utils"
+	"sort"
+)
+
+// DefaultScanCount the default number of keys visited by Scan in one call.
+const DefaultScanCount = 10
+
+// the position of the data type in the scan cursor, the lower bits are the position in the index.KeySet.
+const scanTypeShift = 61
+
+// ScanOptions the options of Scan.
+type ScanOptions struct {
+	// Match only the keys matching the glob-style pattern are returned, such as "user:*".
+	Match string
+
+	// Count the number of keys visited in one call, DefaultScanCount is used if it is not positive.
+	// Fewer keys are returned if some of them are filtered out by Match, or at the end of the iteration.
+	Count int
+
+	// Types only the keys of the data types are returned, all the data types are scanned if it is empty.
+	Types []DataType
+}
+
+// Type returns the data type of key, ok is false if key does not exist.
+// A key can be saved in several data types, the first one in the order of String, List, Hash, Set and ZSet is returned.
+func (db *FastDB) Type(key []byte) (dType DataType, ok bool) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.rLockAll()
+	defer db.rUnlockAll()
+
+	for dType = String; dType < DataStructureNum; dType++ {
+		if db.keyExists(key, dType) {
+			return dType, true
+		}
+	}
+	return 0, false
+}
+
+// Exists returns the number of keys existing in any data type, the same key given several times is counted several times.
+func (db *FastDB) Exists(keys ...[]byte) (count int) {
+	db.rLockAll()
+	defer db.rUnlockAll()
+
+	for _, key := range keys {
+		if db.checkKeyValue(key, nil) != nil {
+			continue
+		}
+		for dType := String; dType < DataStructureNum; dType++ {
+			if db.keyExists(key, dType) {
+				count++
+				break
+			}
+		}
+	}
+	return
+}
+
+// Del removes the keys of all the data types, returns the number of keys removed.
+// Nothing is removed if any key is invalid.
+func (db *FastDB) Del(keys ...[]byte) (count int, err error) {
+	for _, key := range keys {
+		if err = db.checkKeyValue(key, nil); err != nil {
+			return
+		}
+	}
+
+	db.lockAll()
+	defer db.unlockAll()
+
+	for _, key := range keys {
+		var removed bool
+		for dType := String; dType < DataStructureNum; dType++ {
+			if !db.keyInIndex(key, dType) {
+				continue
+			}
+			// the expired or empty key is removed too, but it is not counted.
+			if db.keyExists(key, dType) {
+				removed = true
+			}
+			if err = db.clearKey(key, dType); err != nil {
+				return
+			}
+		}
+		if removed {
+			count++
+		}
+	}
+	return
+}
+
+// DBSize returns the number of keys, a key saved in several data types is counted once.
+func (db *FastDB) DBSize() int {
+	db.rLockAll()
+	defer db.rUnlockAll()
+
+	return len(db.liveKeys())
+}
+
+// FlushDB removes all the keys, the removal of every key is written to the db files, so it is kept after reopening.
+func (db *FastDB) FlushDB() (err error) {
+	db.lockAll()
+	defer db.unlockAll()
+
+	for dType := String; dType < DataStructureNum; dType++ {
+		for _, key := range db.indexKeys(dType) {
+			if err = db.clearKey([]byte(key), dType); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Keys returns all the keys matching the glob-style pattern in order.
+func (db *FastDB) Keys(pattern string) (keys [][]byte) {
+	db.rLockAll()
+	defer db.rUnlockAll()
+
+	var matched []string
+	for key := range db.liveKeys() {
+		if utils.GlobMatch(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+	sort.Strings(matched)
+
+	for _, key := range matched {
+		keys = append(keys, []byte(key))
+	}
+	return
+}
+
+// Scan iterates the keys with a cursor, it starts with the cursor 0, and ends when the returned cursor is 0.
+// The keys of every data type are visited in the order of their hash, and the cursor saves the data type and
+// the hash position of the last key visited, so nothing is saved in the db between the calls.
+// A key existing during the whole iteration is always returned, and the keys added or removed may be returned or not.
+// A key is returned several times if it is saved in several data types, more keys than Count may be returned
+// if their hash positions are the same.
+// Only the index of one data type is locked at a time, and it is unlocked between the calls.
+func (db *FastDB) Scan(cursor uint64, opts ScanOptions) (next uint64, keys [][]byte) {
+	count := opts.Count
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	// the data type is saved in the high bits of the cursor, and 0 means the first data type.
+	dType, pos := String, uint64(0)
+	if cursor != 0 {
+		dType, pos = DataType(cursor>>scanTypeShift-1), cursor&(1<<scanTypeShift-1)
+	}
+
+	for ; dType < DataStructureNum; dType, pos = dType+1, 0 {
+		if !scanType(dType, opts.Types) {
+			continue
+		}
+
+		visited, last := db.scanKeys(dType, pos, count, func(key string) {
+			if opts.Match == "" || utils.GlobMatch(opts.Match, key) {
+				keys = append(keys, []byte(key))
+			}
+		})
+		if count -= visited; count <= 0 && last != 0 {
+			return uint64(dType+1)<<scanTypeShift | last, keys
+		}
+	}
+	return 0, keys
+}
+
+// visit at least count existing keys of the data type after the position of the key set,
+// returns the number of keys visited, including the expired and empty ones, and the next position, 0 means the end.
+func (db *FastDB) scanKeys(dType DataType, pos uint64, count int, fn func(key string)) (visited int, next uint64) {
+	lock := db.getIdxLock(dType)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var keys []string
+	switch dType {
+	case String:
+		keys, next = db.strIndex.keys.Scan(pos, count)
+	case List:
+		keys, next = db.listIndex.indexes.ScanKeys(pos, count)
+	case Hash:
+		keys, next = db.hashIndex.indexes.ScanKeys(pos, count)
+	case Set:
+		keys, next = db.setIndex.indexes.ScanKeys(pos, count)
+	case ZSet:
+		keys, next = db.zsetIndex.indexes.ScanKeys(pos, count)
+	}
+
+	for _, k := range keys {
+		if db.keyExists([]byte(k), dType) {
+			fn(k)
+		}
+	}
+	return len(keys), next
+}
+
+func scanType(dType DataType, dTypes []DataType) bool {
+	if len(dTypes) == 0 {
+		return true
+	}
+	for _, t := range dTypes {
+		if t == dType {
+			return true
+		}
+	}
+	return false
+}
+
+// the keys which exist in any data type.
+// The caller should hold the read locks of all the data types.
+func (db *FastDB) liveKeys() map[string]struct{} {
+	keys := make(map[string]struct{})
+	for dType := String; dType < DataStructureNum; dType++ {
+		for _, key := range db.indexKeys(dType) {
+			if db.keyExists([]byte(key), dType) {
+				keys[key] = struct{}{}
+			}
+		}
+	}
+	return keys
+}
+
+// all the keys in the indexes of the data type, including the expired ones.
+func (db *FastDB) indexKeys(dType DataType) (keys []string) {
+	switch dType {
+	case String:
+		for e := db.strIndex.idxList.Front(); e != nil; e = e.Next() {
+			keys = append(keys, string(e.Key()))
+		}
+	case List:
+		keys = db.listIndex.indexes.Keys()
+	case Hash:
+		keys = db.hashIndex.indexes.Keys()
+	case Set:
+		keys = db.setIndex.indexes.Keys()
+	case ZSet:
+		keys = db.zsetIndex.indexes.Keys()
+	}
+	return
+}
+
+// check whether key is in the indexes of the data type, it may be expired.
+func (db *FastDB) keyInIndex(key []byte, dType DataType) bool {
+	switch dType {
+	case String:
+		return db.strIndex.idxList.Exist(key)
+	case List:
+		return db.listIndex.indexes.LKeyExists(string(key))
+	case Hash:
+		return db.hashIndex.indexes.HKeyExists(string(key))
+	case Set:
+		return db.setIndex.indexes.SKeyExists(string(key))
+	case ZSet:
+		return db.zsetIndex.indexes.ZKeyExists(string(key))
+	}
+	return false
+}
+
+// check whether key exists in the data type, the expired key and the empty collection are treated as not existing.
+func (db *FastDB) keyExists(key []byte, dType DataType) bool {
+	if !db.keyInIndex(key, dType) || db.isExpired(key, dType) {
+		return false
+	}
+
+	k := string(key)
+	switch dType {
+	case List:
+		return db.listIndex.indexes.LLen(k) > 0
+	case Hash:
+		return db.hashIndex.indexes.HLen(k) > 0
+	case Set:
+		return db.setIndex.indexes.SCard(k) > 0
+	case ZSet:
+		return db.zsetIndex.indexes.ZCard(k) > 0
+	}
+	return true
+}
+
+// lock the indexes of all the data types in order.
+func (db *FastDB) lockAll() {
+	for dType := String; dType < DataStructureNum; dType++ {
+		db.getIdxLock(dType).Lock()
+	}
+}
+
+func (db *FastDB) unlockAll() {
+	for dType := DataType(DataStructureNum); dType > String; dType-- {
+		db.getIdxLock(dType - 1).Unlock()
+	}
+}
+
+func (db *FastDB) rLockAll() {
+	for dType := String; dType < DataStructureNum; dType++ {
+		db.getIdxLock(dType).RLock()
+	}
+}
+
+func (db *FastDB) rUnlockAll() {
+	for dType := DataType(DataStructureNum); dType > String; dType-- {
+		db.getIdxLock(dType - 1).RUnlock()
+	}
+}
@@ -0,0 +1,139 @@
+package fastdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initKeyspaceDb(t *testing.T, mode DataIndexMode) *FastDB {
+	db := InitTestDb(t, mode)
+	_ = db.Set([]byte("str"), []byte("val"))
+	_, _ = db.LPush([]byte("list"), []byte("a"), []byte("b"))
+	_, _ = db.HSet([]byte("hash"), []byte("f"), []byte("val"))
+	_, _ = db.SAdd([]byte("set"), []byte("a"))
+	_, _ = db.ZAdd([]byte("zset"), 1, []byte("a"))
+	// the same key in two data types.
+	_ = db.Set([]byte("both"), []byte("val"))
+	_, _ = db.SAdd([]byte("both"), []byte("a"))
+	return db
+}
+
+func TestFastDB_Keyspace(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		db := initKeyspaceDb(t, mode)
+
+		types := map[string]DataType{"str": String, "list": List, "hash": Hash, "set": Set, "zset": ZSet, "both": String}
+		for k, dType := range types {
+			res, ok := db.Type([]byte(k))
+			assert.True(t, ok)
+			assert.Equal(t, dType, res, k)
+		}
+		_, ok := db.Type([]byte("not_exist"))
+		assert.False(t, ok)
+
+		assert.Equal(t, 3, db.Exists([]byte("str"), []byte("str"), []byte("zset"), []byte("not_exist")))
+		assert.Equal(t, 6, db.DBSize())
+		assert.Equal(t, [][]byte{[]byte("set"), []byte("str")}, db.Keys("s*"))
+
+		// the expired and the empty keys do not exist.
+		_ = db.Expire([]byte("str"), 100)
+		db.expires[String]["str"] = time.Now().Unix() - 1
+		_, _ = db.LPop([]byte("list"))
+		_, _ = db.LPop([]byte("list"))
+		assert.Equal(t, 0, db.Exists([]byte("str"), []byte("list")))
+		assert.Equal(t, 4, db.DBSize())
+
+		// nothing is removed if a key is invalid.
+		_, err := db.Del([]byte("hash"), nil)
+		assert.Equal(t, ErrEmptyKey, err)
+		assert.Equal(t, 1, db.Exists([]byte("hash")))
+
+		count, err := db.Del([]byte("both"), []byte("hash"), []byte("str"), []byte("not_exist"))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+		assert.Nil(t, db.SMembers([]byte("both")))
+		assert.Nil(t, db.HGet([]byte("hash"), []byte("f")))
+
+		db = CloseAndReopen(t, db)
+		assert.Equal(t, [][]byte{[]byte("set"), []byte("zset")}, db.Keys("*"))
+		_, err = db.Get([]byte("both"))
+		assert.Equal(t, ErrKeyNotExist, err)
+		_, err = db.Get([]byte("str"))
+		assert.Equal(t, ErrKeyNotExist, err)
+
+		assert.Nil(t, db.FlushDB())
+		assert.Equal(t, 0, db.DBSize())
+		db = CloseAndReopen(t, db)
+		assert.Equal(t, 0, db.DBSize())
+		assert.Equal(t, 0, db.SCard([]byte("set")))
+		_ = db.Close()
+	}
+}
+
+func TestFastDB_Scan(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	for i := 0; i < 100; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("str-%d", i)), []byte("val"))
+		_, _ = db.HSet([]byte(fmt.Sprintf("hash-%d", i)), []byte("f"), []byte("val"))
+	}
+
+	scanAll := func(opts ScanOptions) map[string]int {
+		seen := make(map[string]int)
+		var cursor uint64
+		for {
+			next, keys := db.Scan(cursor, opts)
+			for _, k := range keys {
+				seen[string(k)]++
+			}
+			if next == 0 {
+				return seen
+			}
+			cursor = next
+		}
+	}
+
+	seen := scanAll(ScanOptions{Count: 7})
+	assert.Equal(t, 200, len(seen))
+	for k, n := range seen {
+		assert.Equal(t, 1, n, k)
+	}
+
+	seen = scanAll(ScanOptions{Match: "str-1*", Count: 10})
+	assert.Equal(t, 11, len(seen))
+
+	seen = scanAll(ScanOptions{Types: []DataType{Hash}, Count: 10})
+	assert.Equal(t, 100, len(seen))
+	assert.Equal(t, 1, seen["hash-99"])
+
+	next, keys := db.Scan(0, ScanOptions{Count: 1000})
+	assert.Zero(t, next)
+	assert.Equal(t, 200, len(keys))
+
+	// the keys existing during the whole iteration are returned, though some keys are added and removed.
+	seen = make(map[string]int)
+	next, keys = db.Scan(0, ScanOptions{Count: 50})
+	for _, k := range keys {
+		seen[string(k)]++
+	}
+	for i := 0; i < 100; i += 2 {
+		_ = db.StrRem([]byte(fmt.Sprintf("str-%d", i)))
+		_ = db.Set([]byte(fmt.Sprintf("str-new-%d", i)), []byte("val"))
+	}
+	for next != 0 {
+		next, keys = db.Scan(next, ScanOptions{Count: 50})
+		for _, k := range keys {
+			seen[string(k)]++
+		}
+	}
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("hash-%d", i)])
+		if i%2 == 1 {
+			assert.Equal(t, 1, seen[fmt.Sprintf("str-%d", i)])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package fastdb
+
+import (
+	"fastdb/storage"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Migrate upgrade the db files in dirPath from the format without file header to the current format, returns the number of the migrated files.
+// The entries of the old db files are copied in the same order to the new db files, which may have different file ids.
+// The old files are replaced like a reclaim, so an interrupted migration will be completed the next time the db is opened.
+// It must be executed while the db is closed.
+func Migrate(dirPath string) (migrated int, err error) {
+	config, err := loadConfig(dirPath)
+	if err != nil {
+		return
+	}
+	if err = recoverReclaim(dirPath); err != nil {
+		return
+	}
+
+	fileIds, err := storage.FileIds(dirPath)
+	if err != nil {
+		return
+	}
+
+	path := dirPath + reclaimPath
+	if err = os.MkdirAll(path, os.ModePerm); err != nil {
+		return
+	}
+	defer os.RemoveAll(path)
+
+	info := &reclaimInfo{OldFileIds: make(map[DataType][]uint32), NewFileIds: make(map[DataType][]uint32)}
+	meta := storage.LoadMeta(dirPath + dbMetaSaveFile)
+	for dType := String; dType < DataStructureNum; dType++ {
+		var legacy bool
+		if legacy, err = isLegacy(dirPath, dType, fileIds[dType]); err != nil {
+			return
+		}
+		if !legacy {
+			continue
+		}
+
+		w := &reclaimWriter{path: path, dType: dType, config: config, files: make(map[uint32]*storage.DBFile)}
+		err = migrateFiles(w, dirPath, fileIds[dType])
+		if e := w.close(); e != nil && err == nil {
+			err = e
+		}
+		if err != nil {
+			return
+		}
+
+		for _, id := range fileIds[dType] {
+			info.OldFileIds[dType] = append(info.OldFileIds[dType], uint32(id))
+		}
+		for id := range w.files {
+			info.NewFileIds[dType] = append(info.NewFileIds[dType], id)
+		}
+		migrated += len(fileIds[dType])
+
+		// the write offsets are found when opening the db, and the reclaimable space of the old String files is useless.
+		delete(meta.ActiveWriteOff, dType)
+		if dType == String {
+			meta.ReclaimableSpace = make(map[uint32]int64)
+		}
+	}
+	if migrated == 0 {
+		return
+	}
+
+	if err = meta.Store(dirPath + dbMetaSaveFile); err != nil {
+		return
+	}
+	// From now on, the new db files will replace the old ones even if the process crashes.
+	if err = info.store(path + reclaimInfoFile); err != nil {
+		return
+	}
+	err = info.apply(dirPath)
+	return
+}
+
+// check if the db files of the data type are in the format without file header.
+func isLegacy(dirPath string, dType DataType, fileIds []int) (bool, error) {
+	var count int
+	for _, id := range fileIds {
+		version, err := storage.FileVersion(dirPath, uint32(id), dType)
+		if err != nil {
+			return false, err
+		}
+		if version == 0 {
+			count++
+		}
+	}
+	if count > 0 && count < len(fileIds) {
+		return false, fmt.Errorf("rosedb: the db files of %s are in different formats", storage.DBFileSuffixName[dType])
+	}
+	return count > 0, nil
+}
+
+// copy all the entries of the old db files, the batch entries are copied as they are, they will be resolved when loading.
+func migrateFiles(w *reclaimWriter, dirPath string, fileIds []int) error {
+	for i, id := range fileIds {
+		df, err := storage.OpenLegacyDBFile(dirPath, uint32(id), w.dType, w.config.BlockSize)
+		if err != nil {
+			return err
+		}
+
+		var offset int64 = 0
+		for {
+			e, err := df.Read(offset)
+			if err == io.EOF {
+				break
+			}
+			// the old versions fill the files with zero in MMap, which may be shorter than an entry header.
+			if err != nil || len(e.Meta.Key) == 0 {
+				if zero, _ := df.IsZero(offset); zero {
+					break
+				}
+			}
+			if err != nil {
+				// drop the torn write at the tail of the last file, the same as opening the db.
+				if tail, _ := df.IsTail(offset); tail && i == len(fileIds)-1 {
+					log.Printf("rosedb: dropped the torn write of db file %s at offset %d.[%+v]",
+						fmt.Sprintf(storage.DBFileFormatNames[w.dType], id), offset, err)
+					break
+				}
+				_ = df.Close(false)
+				return fmt.Errorf("%w, file: %s, offset: %d.[%+v]",
+					ErrDBFileCorrupted, fmt.Sprintf(storage.DBFileFormatNames[w.dType], id), offset, err)
+			}
+			offset += int64(e.Size())
+
+			if len(e.Meta.Key) == 0 {
+				continue
+			}
+			if _, _, err = w.append(e); err != nil {
+				_ = df.Close(false)
+				return err
+			}
+		}
+		if err = df.Close(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package fastdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fastdb/storage"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encode the entry in the format of the old versions, which have no file header, and the crc only covers the value.
+func encodeLegacyEntry(key, value, extra []byte, dType DataType, mark uint16) []byte {
+	buf := make([]byte, storage.EntryHeaderSize+len(key)+len(value)+len(extra))
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(value))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(key)))
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(value)))
+	binary.BigEndian.PutUint32(buf[12:16], uint32(len(extra)))
+	binary.BigEndian.PutUint16(buf[16:18], dType<<8|mark)
+	binary.BigEndian.PutUint64(buf[18:26], uint64(time.Now().UnixNano()))
+	copy(buf[storage.EntryHeaderSize:], key)
+	copy(buf[storage.EntryHeaderSize+len(key):], value)
+	copy(buf[storage.EntryHeaderSize+len(key)+len(value):], extra)
+	return buf
+}
+
+// write the db files of the old versions, the files are filled with zero to the block size in MMap.
+func writeLegacyFiles(t *testing.T, config Config, dType DataType, files [][][]byte) {
+	for id, entries := range files {
+		var b []byte
+		for _, e := range entries {
+			b = append(b, e...)
+		}
+		if config.RwMethod == storage.MMap {
+			b = append(b, make([]byte, config.BlockSize-int64(len(b)))...)
+		}
+		path := filepath.Join(config.DirPath, fmt.Sprintf(storage.DBFileFormatNames[dType], id))
+		if err := ioutil.WriteFile(path, b, storage.FilePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	for _, method := range []storage.FileRWMethod{storage.FileIO, storage.MMap} {
+		t.Run(fmt.Sprintf("method %d", method), func(t *testing.T) {
+			config := DefaultConfig()
+			config.DirPath = t.TempDir()
+			config.RwMethod = method
+			config.MaxKeySize, config.MaxValueSize, config.BlockSize = 64, 1024, 4*1024
+			db, err := Open(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Nil(t, db.Close())
+			files, _ := filepath.Glob(filepath.Join(config.DirPath, "*.data.*"))
+			for _, f := range files {
+				assert.Nil(t, os.Remove(f))
+			}
+
+			// the old versions write a new file when the entry can`t be held by the block.
+			var strFiles [][][]byte
+			var entries [][]byte
+			var size int
+			for i := 0; i < 200; i++ {
+				e := encodeLegacyEntry([]byte("key"), []byte(fmt.Sprintf("val-%d", i)), nil, String, StringSet)
+				if size+len(e) > int(config.BlockSize) {
+					strFiles = append(strFiles, entries)
+					entries, size = nil, 0
+				}
+				entries = append(entries, e)
+				size += len(e)
+			}
+			entries = append(entries, encodeLegacyEntry([]byte("k1"), []byte("v1"), nil, String, StringSet))
+			strFiles = append(strFiles, entries)
+			writeLegacyFiles(t, config, String, strFiles)
+			writeLegacyFiles(t, config, Hash, [][][]byte{{
+				encodeLegacyEntry([]byte("h1"), []byte("v1"), []byte("f1"), Hash, HashHSet),
+				encodeLegacyEntry([]byte("h1"), []byte("v2"), []byte("f2"), Hash, HashHSet),
+			}})
+			for _, dType := range []DataType{List, Set, ZSet} {
+				writeLegacyFiles(t, config, dType, [][][]byte{nil})
+			}
+			assert.True(t, len(strFiles) > 1)
+
+			_, err = Reopen(config.DirPath)
+			assert.True(t, errors.Is(err, storage.ErrInvalidFileHeader))
+
+			migrated, err := Migrate(config.DirPath)
+			assert.Nil(t, err)
+			assert.Equal(t, len(strFiles)+DataStructureNum-1, migrated)
+
+			migrated, err = Migrate(config.DirPath)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, migrated)
+
+			db, err = Reopen(config.DirPath)
+			assert.Nil(t, err)
+			defer db.Close()
+			val, _ := db.Get([]byte("key"))
+			assert.Equal(t, []byte("val-199"), val)
+			val, _ = db.Get([]byte("k1"))
+			assert.Equal(t, []byte("v1"), val)
+			assert.Equal(t, []byte("v1"), db.HGet([]byte("h1"), []byte("f1")))
+			assert.Equal(t, []byte("v2"), db.HGet([]byte("h1"), []byte("f2")))
+
+			// the migrated files are written in the current format.
+			_ = db.Set([]byte("k2"), []byte("v2"))
+			db = CloseAndReopen(t, db)
+			val, _ = db.Get([]byte("k2"))
+			assert.Equal(t, []byte("v2"), val)
+		})
+	}
+}
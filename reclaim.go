@@ -0,0 +1,641 @@
+package fastdb
+
+import (
+	"encoding/json"
+	"fastdb/index"
+	"fastdb/storage"
+	"fastdb/utils"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The reclaim info is saved in reclaim path after all the new db files are written.
+// So an interrupted reclaim can be completed the next time the db is opened.
+const reclaimInfoFile = string(os.PathSeparator) + "RECLAIM.META"
+
+type (
+	// reclaimInfo the db files involved in a reclaim.
+	reclaimInfo struct {
+		OldFileIds map[DataType][]uint32 `json:"old_file_ids"` // archived files to be removed.
+		NewFileIds map[DataType][]uint32 `json:"new_file_ids"` // reclaimed files to be moved to the db dir.
+	}
+
+	// reclaimWriter writes the valid entries to the new db files in the reclaim path.
+	reclaimWriter struct {
+		path        string
+		dType       DataType
+		config      Config
+		df          *storage.DBFile
+		files       map[uint32]*storage.DBFile
+		nextId      uint32
+		idxUpdate   []reclaimedIdx
+		fieldUpdate []reclaimedField
+		hints       map[uint32][]*storage.Hint // the hints of the new String db files.
+	}
+
+	// reclaimedIdx the new position of a String entry, the indexer will be updated after the reclaim is done.
+	reclaimedIdx struct {
+		idx       *index.Indexer
+		fileId    uint32
+		offset    int64
+		oldOffset int64
+	}
+
+	// reclaimedField the new position of a hash field in KeyOnlyMemMode, the hash indexes will be updated after the reclaim is done.
+	reclaimedField struct {
+		key    string
+		field  string
+		fileId uint32
+		offset int64
+	}
+)
+
+// Reclaim reclaim db files`s redundant space in disk.
+// The types whose number of archived files exceeds the ReclaimThreshold will be reclaimed.
+// For String, all archived files are read and only the entries pointed by the skip list indexes are rewritten.
+// For List, Hash, Set and ZSet, the active file is archived first, then the data structures in memory are rewritten as new db files.
+// All writes are blocked while reclaiming, so you`d better execute it in low peak period.
+func (db *FastDB) Reclaim() (err error) {
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// if single reclaiming is in progress, the reclaim operation can`t be executed.
+	if db.isSingleReclaiming {
+		return ErrDBisReclaiming
+	}
+
+	var reclaimTypes []DataType
+	for dType := 0; dType < DataStructureNum; dType++ {
+		if len(db.archFiles[uint16(dType)]) > db.config.ReclaimThreshold {
+			reclaimTypes = append(reclaimTypes, uint16(dType))
+		}
+	}
+	if len(reclaimTypes) == 0 {
+		return ErrReclaimUnreached
+	}
+
+	db.isReclaiming = true
+	defer func() {
+		db.isReclaiming = false
+	}()
+
+	// create a temporary directory for storing the new db files.
+	path := db.config.DirPath + reclaimPath
+	if err = os.RemoveAll(path); err != nil {
+		return
+	}
+	if err = os.MkdirAll(path, os.ModePerm); err != nil {
+		return
+	}
+	defer os.RemoveAll(path)
+
+	// the data in memory can only be rewritten when all the entries are in archived files.
+	for _, dType := range reclaimTypes {
+		if dType != String && db.activeFile[dType].Offset > storage.FileHeaderSize {
+			if err = db.archiveActiveFile(dType); err != nil {
+				return
+			}
+		}
+	}
+
+	// processing the different types of files in different goroutines.
+	writers := make([]*reclaimWriter, len(reclaimTypes))
+	errs := make([]error, len(reclaimTypes))
+	wg := sync.WaitGroup{}
+	wg.Add(len(reclaimTypes))
+	for i, dType := range reclaimTypes {
+		go func(i int, dType DataType) {
+			defer wg.Done()
+
+			w := &reclaimWriter{path: path, dType: dType, config: db.config, files: make(map[uint32]*storage.DBFile)}
+			writers[i] = w
+			if dType == String {
+				errs[i] = db.reclaimStrFiles(w)
+			} else {
+				errs[i] = db.rewriteFromMemory(w)
+			}
+		}(i, dType)
+	}
+	wg.Wait()
+
+	info := &reclaimInfo{OldFileIds: make(map[DataType][]uint32), NewFileIds: make(map[DataType][]uint32)}
+	for i, w := range writers {
+		if err = w.close(); err != nil && errs[i] == nil {
+			errs[i] = err
+		}
+	}
+	for i, w := range writers {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		for id := range db.archFiles[w.dType] {
+			info.OldFileIds[w.dType] = append(info.OldFileIds[w.dType], id)
+		}
+		for id := range w.files {
+			info.NewFileIds[w.dType] = append(info.NewFileIds[w.dType], id)
+		}
+	}
+
+	// From now on, the new db files will replace the old ones even if the process crashes.
+	if err = info.store(path + reclaimInfoFile); err != nil {
+		return
+	}
+	for _, dType := range reclaimTypes {
+		for _, f := range db.archFiles[dType] {
+			_ = f.Close(false)
+		}
+	}
+	if err = info.apply(db.config.DirPath); err != nil {
+		return
+	}
+
+	// reopen the new archived files.
+	for _, w := range writers {
+		files := make(map[uint32]*storage.DBFile)
+		for id := range w.files {
+			if files[id], err = storage.NewDBFile(db.config.DirPath, id, db.config.RwMethod, db.config.BlockSize, w.dType); err != nil {
+				return
+			}
+		}
+		db.archFiles[w.dType] = files
+		for id, hints := range w.hints {
+			if err := storage.WriteHintFile(db.config.DirPath, id, files[id].Header, hints); err != nil {
+				log.Printf("rosedb: write the hint file err: %+v", err)
+			}
+		}
+
+		// update the position of String indexes and hash fields.
+		for _, u := range w.idxUpdate {
+			u.idx.FileId = u.fileId
+			u.idx.Offset = u.offset
+		}
+		for _, u := range w.fieldUpdate {
+			db.hashIndex.indexes.HSet(u.key, u.field, db.hashIdxVal(nil, u.fileId, u.offset))
+		}
+		if w.dType == String {
+			for _, id := range info.OldFileIds[String] {
+				delete(db.meta.ReclaimableSpace, id)
+			}
+		}
+	}
+	return
+}
+
+// SingleReclaim reclaim the archived String db files whose reclaimable space reached the SingleReclaimThreshold.
+// The valid entries of a db file are copied to a new db file while the db still serves reads and writes,
+// only replacing the old db file blocks the String operations.
+func (db *FastDB) SingleReclaim() (err error) {
+	db.mu.Lock()
+	if db.isReclaiming || db.isSingleReclaiming {
+		db.mu.Unlock()
+		return ErrDBisReclaiming
+	}
+	db.isSingleReclaiming = true
+	db.mu.Unlock()
+
+	defer func() {
+		db.mu.Lock()
+		db.isSingleReclaiming = false
+		db.mu.Unlock()
+	}()
+
+	// find the db files which can be reclaimed.
+	var fileIds []int
+	db.strIndex.mu.RLock()
+	for id := range db.archFiles[String] {
+		if db.meta.ReclaimableSpace[id] >= db.config.SingleReclaimThreshold {
+			fileIds = append(fileIds, int(id))
+		}
+	}
+	db.strIndex.mu.RUnlock()
+	if len(fileIds) == 0 {
+		return ErrReclaimUnreached
+	}
+	sort.Ints(fileIds)
+
+	path := db.config.DirPath + reclaimPath
+	if err = os.MkdirAll(path, os.ModePerm); err != nil {
+		return
+	}
+	defer os.RemoveAll(path)
+
+	for _, fid := range fileIds {
+		if err = db.singleReclaimFile(path, uint32(fid)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// copy the valid entries of a String db file to a new db file with the same id, and replace the old one.
+func (db *FastDB) singleReclaimFile(path string, fileId uint32) error {
+	db.strIndex.mu.RLock()
+	df := db.archFiles[String][fileId]
+	db.strIndex.mu.RUnlock()
+
+	w := &reclaimWriter{path: path, dType: String, config: db.config, files: make(map[uint32]*storage.DBFile), nextId: fileId}
+
+	// The entries are copied with their batch flags, since the other entries of the batch may be in the other files.
+	// A commit entry is kept if some entries of its batch are copied, or all the entries before it in the file belong to
+	// the batch, then the batch may begin in the previous file.
+	copied := make(map[uint64]bool)
+	var head uint64
+	var headRun bool
+
+	var offset int64 = storage.FileHeaderSize
+	for offset <= db.config.BlockSize {
+		e, err := df.Read(offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = w.close()
+			return err
+		}
+		if len(e.Meta.Key) == 0 {
+			break
+		}
+
+		batchId, batched := e.BatchId()
+		if offset == storage.FileHeaderSize {
+			head, headRun = batchId, batched
+		} else if !batched || batchId != head {
+			headRun = false
+		}
+
+		var valid bool
+		var idx *index.Indexer
+		if e.IsBatchCommit() {
+			valid = copied[batchId] || headRun
+			headRun = false
+		} else {
+			// only hold the read lock while checking, the writes can continue during copying.
+			db.strIndex.mu.RLock()
+			idx = db.validStrEntry(e, fileId, offset)
+			valid = idx != nil || (e.GetMark() == StringExpire && db.validStrExpire(e))
+			db.strIndex.mu.RUnlock()
+		}
+
+		if valid {
+			_, newOffset, err := w.append(e)
+			if err != nil {
+				_ = w.close()
+				return err
+			}
+			if batched {
+				copied[batchId] = true
+			}
+			if idx != nil {
+				w.idxUpdate = append(w.idxUpdate, reclaimedIdx{idx: idx, fileId: fileId, offset: newOffset, oldOffset: offset})
+			}
+		}
+		offset += int64(e.Size())
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// remove the hint file of the old db file first, the new one is scanned if its hint file fails to be written.
+	if err := storage.RemoveHintFile(db.config.DirPath, fileId); err != nil {
+		return err
+	}
+
+	// the old db file can be replaced atomically since the new one has the same name.
+	_ = df.Close(false)
+	name := string(os.PathSeparator) + fmt.Sprintf(storage.DBFileFormatNames[String], fileId)
+	if len(w.files) == 0 {
+		if err := os.Remove(db.config.DirPath + name); err != nil {
+			return err
+		}
+		delete(db.archFiles[String], fileId)
+		delete(db.meta.ReclaimableSpace, fileId)
+		return nil
+	}
+	if err := os.Rename(path+name, db.config.DirPath+name); err != nil {
+		return err
+	}
+	newDf, err := storage.NewDBFile(db.config.DirPath, fileId, db.config.RwMethod, db.config.BlockSize, String)
+	if err != nil {
+		return err
+	}
+	db.archFiles[String][fileId] = newDf
+	if err := storage.WriteHintFile(db.config.DirPath, fileId, newDf.Header, w.hints[fileId]); err != nil {
+		log.Printf("rosedb: write the hint file err: %+v", err)
+	}
+
+	// the entries updated or removed during copying are still reclaimable in the new db file.
+	var reclaimable int64
+	for _, u := range w.idxUpdate {
+		node := db.strIndex.idxList.Get(u.idx.Meta.Key)
+		if node == nil || node.Value().(*index.Indexer) != u.idx || u.idx.FileId != fileId || u.idx.Offset != u.oldOffset {
+			reclaimable += int64(u.idx.EntrySize)
+			continue
+		}
+		u.idx.Offset = u.offset
+	}
+	db.meta.ReclaimableSpace[fileId] = reclaimable
+	return nil
+}
+
+// reclaim the String db files periodically in background.
+func (db *FastDB) singleReclaimLoop() {
+	defer db.bgWg.Done()
+
+	ticker := time.NewTicker(db.config.SingleReclaimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.SingleReclaim(); err != nil && err != ErrReclaimUnreached && err != ErrDBisReclaiming {
+				log.Printf("single reclaim err: %+v", err)
+			}
+		case <-db.closed:
+			return
+		}
+	}
+}
+
+// read all the archived String files, and rewrite the valid entries.
+func (db *FastDB) reclaimStrFiles(w *reclaimWriter) error {
+	var fileIds []int
+	for id := range db.archFiles[String] {
+		fileIds = append(fileIds, int(id))
+	}
+	sort.Ints(fileIds)
+
+	for _, fid := range fileIds {
+		df := db.archFiles[String][uint32(fid)]
+		var offset int64 = storage.FileHeaderSize
+
+		for offset <= db.config.BlockSize {
+			e, err := df.Read(offset)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			// an empty key means reaching the end of the written data.
+			if len(e.Meta.Key) == 0 {
+				break
+			}
+
+			if idx := db.validStrEntry(e, uint32(fid), offset); idx != nil || (e.GetMark() == StringExpire && db.validStrExpire(e)) {
+				fileId, newOffset, err := w.write(e)
+				if err != nil {
+					return err
+				}
+				if idx != nil {
+					w.idxUpdate = append(w.idxUpdate, reclaimedIdx{idx: idx, fileId: fileId, offset: newOffset, oldOffset: offset})
+				}
+			}
+			offset += int64(e.Size())
+		}
+	}
+	return nil
+}
+
+// returns the indexer if the String entry at the position is still in use.
+func (db *FastDB) validStrEntry(e *storage.Entry, fileId uint32, offset int64) *index.Indexer {
+	mark := e.GetMark()
+	if mark != StringSet && mark != StringPersist && mark != StringSetEx {
+		return nil
+	}
+
+	if deadline, exist := db.expires[String][string(e.Meta.Key)]; exist && deadline <= time.Now().Unix() {
+		return nil
+	}
+
+	node := db.strIndex.idxList.Get(e.Meta.Key)
+	if node == nil {
+		return nil
+	}
+	idx := node.Value().(*index.Indexer)
+	if idx == nil || idx.FileId != fileId || idx.Offset != offset {
+		return nil
+	}
+	return idx
+}
+
+// check whether the expire entry is the current deadline of the key.
+func (db *FastDB) validStrExpire(e *storage.Entry) bool {
+	deadline, exist := db.expires[String][string(e.Meta.Key)]
+	return exist && deadline > time.Now().Unix() && uint64(deadline) == e.Timestamp
+}
+
+// rewrite the whole data of List, Hash, Set or ZSet in memory, every key is saved as the operations which can build it again.
+func (db *FastDB) rewriteFromMemory(w *reclaimWriter) (err error) {
+	var keys []string
+	switch w.dType {
+	case List:
+		keys = db.listIndex.indexes.Keys()
+	case Hash:
+		keys = db.hashIndex.indexes.Keys()
+	case Set:
+		keys = db.setIndex.indexes.Keys()
+	case ZSet:
+		keys = db.zsetIndex.indexes.Keys()
+	}
+	sort.Strings(keys)
+
+	now := time.Now().Unix()
+	for _, k := range keys {
+		key := []byte(k)
+		deadline, expiring := db.expires[w.dType][k]
+		if expiring && deadline <= now {
+			continue
+		}
+
+		var entries []*storage.Entry
+		switch w.dType {
+		case List:
+			for _, v := range db.listIndex.indexes.LRange(k, 0, -1) {
+				entries = append(entries, storage.NewEntryNoExtra(key, v, List, ListRPush))
+			}
+		case Hash:
+			vals := db.hashIndex.indexes.HGetAll(k)
+			for i := 0; i < len(vals); i += 2 {
+				val := vals[i+1]
+				// the values are in the archived files, db.mu is held, so read them directly.
+				if db.config.IdxMode == KeyOnlyMemMode {
+					fileId, offset := decodeHashPos(val)
+					var e *storage.Entry
+					if e, err = db.archFiles[Hash][fileId].Read(offset); err != nil {
+						return
+					}
+					val = e.Meta.Value
+				}
+				entries = append(entries, storage.NewEntry(key, val, vals[i], Hash, HashHSet))
+			}
+		case Set:
+			for _, m := range db.setIndex.indexes.SMembers(k) {
+				entries = append(entries, storage.NewEntryNoExtra(key, m, Set, SetSAdd))
+			}
+		case ZSet:
+			vals := db.zsetIndex.indexes.ZRangeWithScores(k, 0, -1)
+			for i := 0; i < len(vals); i += 2 {
+				member, score := vals[i].(string), vals[i+1].(float64)
+				entries = append(entries, storage.NewEntry(key, []byte(member), encodeScore(score), ZSet, ZSetZAdd))
+			}
+		}
+		if len(entries) > 0 && expiring {
+			entries = append(entries, storage.NewEntryWithExpire(key, nil, deadline, w.dType, expireMarks[w.dType]))
+		}
+
+		for _, e := range entries {
+			fileId, offset, err := w.write(e)
+			if err != nil {
+				return err
+			}
+			if w.dType == Hash && e.GetMark() == HashHSet && db.config.IdxMode == KeyOnlyMemMode {
+				w.fieldUpdate = append(w.fieldUpdate, reclaimedField{key: k, field: string(e.Meta.Extra), fileId: fileId, offset: offset})
+			}
+		}
+	}
+	return
+}
+
+// write entry to the new db file, returns the position of the entry.
+func (w *reclaimWriter) write(e *storage.Entry) (fileId uint32, offset int64, err error) {
+	// only the committed entries are copied, and the commit entries are dropped.
+	e.Unbatch()
+	return w.append(e)
+}
+
+// write entry to the new db file as it is.
+func (w *reclaimWriter) append(e *storage.Entry) (fileId uint32, offset int64, err error) {
+	if w.df == nil || w.df.Offset+int64(e.Size()) > w.config.BlockSize {
+		if w.df != nil {
+			if err = w.df.Sync(); err != nil {
+				return
+			}
+		}
+		if w.df, err = storage.NewDBFile(w.path, w.nextId, w.config.RwMethod, w.config.BlockSize, w.dType); err != nil {
+			return
+		}
+		w.files[w.nextId] = w.df
+		w.nextId++
+	}
+
+	if err = w.df.Write(e); err != nil {
+		return
+	}
+	fileId, offset = w.df.Id, w.df.Offset-int64(e.Size())
+	if w.dType == String {
+		if w.hints == nil {
+			w.hints = make(map[uint32][]*storage.Hint)
+		}
+		w.hints[fileId] = append(w.hints[fileId], storage.NewHint(e, fileId, offset))
+	}
+	return
+}
+
+// sync and close all the new db files.
+func (w *reclaimWriter) close() (err error) {
+	if w == nil {
+		return
+	}
+	for _, f := range w.files {
+		if e := f.Close(true); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (info *reclaimInfo) store(path string) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(b); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// move the new db files to the db dir and remove the old ones.
+// It can be executed repeatedly, the new db files are moved first, so they will never be removed.
+func (info *reclaimInfo) apply(dirPath string) error {
+	fileName := func(dType DataType, id uint32) string {
+		return string(os.PathSeparator) + fmt.Sprintf(storage.DBFileFormatNames[dType], id)
+	}
+
+	for dType, ids := range info.NewFileIds {
+		for _, id := range ids {
+			name := fileName(dType, id)
+			if err := os.Rename(dirPath+reclaimPath+name, dirPath+name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	for dType, ids := range info.OldFileIds {
+		newIds := make(map[uint32]bool)
+		for _, id := range info.NewFileIds[dType] {
+			newIds[id] = true
+		}
+		for _, id := range ids {
+			if newIds[id] {
+				continue
+			}
+			if err := os.Remove(dirPath + fileName(dType, id)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if dType == String {
+				if err := storage.RemoveHintFile(dirPath, id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// complete the interrupted reclaim if the reclaim info was saved, otherwise the new db files are just discarded.
+func recoverReclaim(dirPath string) error {
+	path := dirPath + reclaimPath
+	if !utils.Exist(path) {
+		return nil
+	}
+
+	if b, err := ioutil.ReadFile(path + reclaimInfoFile); err == nil {
+		info := &reclaimInfo{}
+		if err = json.Unmarshal(b, info); err != nil {
+			return err
+		}
+		if err = info.apply(dirPath); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(path)
+}
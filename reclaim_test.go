@@ -0,0 +1,316 @@
+package fastdb
+
+import (
+	"fastdb/storage"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// open a db whose db files are small, so the archived files are generated quickly.
+func initReclaimDb(t *testing.T, mode DataIndexMode) *FastDB {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.IdxMode = mode
+	config.MaxKeySize, config.MaxValueSize, config.BlockSize = 64, 1024, 4*1024
+	config.ReclaimThreshold = 2
+	config.Sync = false
+
+	db, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func countDBFiles(t *testing.T, path, suffix string) (count int) {
+	dir, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dir {
+		if strings.HasSuffix(d.Name(), ".data."+suffix) {
+			count++
+		}
+	}
+	return
+}
+
+func dbFilesSize(t *testing.T, path string) (size int64) {
+	dir, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dir {
+		if strings.Contains(d.Name(), ".data.") {
+			size += d.Size()
+		}
+	}
+	return
+}
+
+func TestFastDB_Reclaim(t *testing.T) {
+	t.Run("unreached", func(t *testing.T) {
+		db := initReclaimDb(t, KeyValueMemMode)
+		defer db.Close()
+
+		_ = db.Set([]byte("k"), []byte("v"))
+		assert.Equal(t, ErrReclaimUnreached, db.Reclaim())
+
+		// the number of archived files must exceed the threshold.
+		for i := 0; len(db.archFiles[String]) < db.config.ReclaimThreshold; i++ {
+			_ = db.Set([]byte(fmt.Sprintf("k%d", i)), make([]byte, 512))
+		}
+		assert.Equal(t, ErrReclaimUnreached, db.Reclaim())
+	})
+
+	t.Run("string", func(t *testing.T) {
+		db := initReclaimDb(t, KeyOnlyMemMode)
+
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("key-%03d", i%100)
+			val := fmt.Sprintf("val-%05d", i)
+			assert.Nil(t, db.Set([]byte(key), []byte(val)))
+		}
+		for i := 0; i < 10; i++ {
+			_ = db.StrRem([]byte(fmt.Sprintf("key-%03d", i)))
+		}
+
+		before := countDBFiles(t, db.config.DirPath, "str")
+		assert.Nil(t, db.Reclaim())
+		assert.True(t, countDBFiles(t, db.config.DirPath, "str") < before)
+		assert.False(t, db.StrExists([]byte("key-005")))
+
+		check := func(db *FastDB) {
+			for i := 10; i < 100; i++ {
+				val, err := db.Get([]byte(fmt.Sprintf("key-%03d", i)))
+				assert.Nil(t, err)
+				assert.Equal(t, fmt.Sprintf("val-%05d", 1900+i), string(val))
+			}
+		}
+		check(db)
+
+		// new writes after reclaiming.
+		assert.Nil(t, db.Set([]byte("key-001"), []byte("new")))
+
+		db = CloseAndReopen(t, db)
+		defer db.Close()
+
+		check(db)
+		val, _ := db.Get([]byte("key-001"))
+		assert.Equal(t, "new", string(val))
+		assert.False(t, db.StrExists([]byte("key-005")))
+	})
+
+	t.Run("list hash set zset", func(t *testing.T) {
+		db := initReclaimDb(t, KeyValueMemMode)
+
+		for i := 0; i < 1000; i++ {
+			key := []byte(fmt.Sprintf("key-%d", i%10))
+			val := []byte(fmt.Sprintf("val-%04d", i))
+			_, _ = db.RPush(key, val)
+			_, _ = db.HSet(key, []byte("field"), val)
+			_, _ = db.SAdd(key, val)
+			_, _ = db.ZAdd(key, float64(i), val)
+			if i%3 != 0 {
+				_, _ = db.LPop(key)
+				_, _ = db.SRem(key, val)
+				_, _ = db.ZRem(key, val)
+			}
+		}
+
+		key := []byte("key-7")
+		list, _ := db.LRange(key, 0, -1)
+		members := sortedMembers(db.SMembers(key))
+		zset := db.ZRangeWithScores(key, 0, -1)
+
+		suffixes := []string{"list", "hash", "set", "zset"}
+		before := make(map[string]int)
+		for _, suffix := range suffixes {
+			before[suffix] = countDBFiles(t, db.config.DirPath, suffix)
+		}
+		assert.Nil(t, db.Reclaim())
+		for _, suffix := range suffixes {
+			assert.True(t, countDBFiles(t, db.config.DirPath, suffix) < before[suffix], suffix)
+		}
+
+		db = CloseAndReopen(t, db)
+		defer db.Close()
+
+		newList, _ := db.LRange(key, 0, -1)
+		assert.Equal(t, list, newList)
+		assert.Equal(t, []byte("val-0997"), db.HGet(key, []byte("field")))
+		assert.Equal(t, members, sortedMembers(db.SMembers(key)))
+		assert.Equal(t, zset, db.ZRangeWithScores(key, 0, -1))
+	})
+}
+
+func TestFastDB_RecoverReclaim(t *testing.T) {
+	db := initReclaimDb(t, KeyValueMemMode)
+	for i := 0; i < 500; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%d", i%10)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	path := db.config.DirPath
+	assert.Nil(t, db.Close())
+
+	// a reclaim which was interrupted before saving the reclaim info should be discarded.
+	assert.Nil(t, os.MkdirAll(path+reclaimPath, os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(path+reclaimPath+"/000000000.data.str", []byte("broken"), 0644))
+
+	db, err := Reopen(path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = os.Stat(path + reclaimPath)
+	assert.True(t, os.IsNotExist(err))
+	val, _ := db.Get([]byte("key-9"))
+	assert.Equal(t, "val-499", string(val))
+}
+
+func TestFastDB_SingleReclaim(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+	db.config.SingleReclaimThreshold = 1024
+
+	assert.Equal(t, ErrReclaimUnreached, db.SingleReclaim())
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%03d", i%50)
+		_ = db.Set([]byte(key), []byte(fmt.Sprintf("val-%05d", i)))
+	}
+	assert.True(t, db.meta.ReclaimableSpace[0] >= 1024)
+	before := dbFilesSize(t, db.config.DirPath)
+	var candidates []uint32
+	for id := range db.archFiles[String] {
+		candidates = append(candidates, id)
+	}
+
+	// the writes during reclaiming should not be lost.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			_ = db.Set([]byte(fmt.Sprintf("key-%03d", i%10)), []byte("updated"))
+		}
+	}()
+	assert.Nil(t, db.SingleReclaim())
+	<-done
+
+	assert.True(t, dbFilesSize(t, db.config.DirPath) < before)
+	for _, id := range candidates {
+		assert.True(t, db.meta.ReclaimableSpace[id] < 1024)
+	}
+
+	check := func(db *FastDB) {
+		for i := 0; i < 50; i++ {
+			val, err := db.Get([]byte(fmt.Sprintf("key-%03d", i)))
+			assert.Nil(t, err)
+			if i < 10 {
+				assert.Equal(t, "updated", string(val))
+			} else {
+				assert.Equal(t, fmt.Sprintf("val-%05d", 950+i), string(val))
+			}
+		}
+	}
+	db = CheckAndReopen(t, db, check)
+	defer db.Close()
+}
+
+func TestFastDB_SingleReclaimHintFailed(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+	db.config.SingleReclaimThreshold = 1024
+	for i := 0; i < 1000; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%03d", i%50)), []byte(fmt.Sprintf("val-%05d", i)))
+	}
+
+	// the hint files can`t be written, since the temp files are occupied by directories.
+	hintFile := func(id uint32) string {
+		return db.config.DirPath + storage.PathSeparator + fmt.Sprintf(storage.HintFileFormatName, id)
+	}
+	for id := range db.archFiles[String] {
+		assert.Nil(t, os.Mkdir(hintFile(id)+".tmp", os.ModePerm))
+	}
+	assert.Nil(t, db.SingleReclaim())
+
+	// the stale hint files of the reclaimed db files are removed, the db files are scanned instead.
+	for id := range db.archFiles[String] {
+		if db.meta.ReclaimableSpace[id] < 1024 {
+			_, err := os.Stat(hintFile(id))
+			assert.True(t, os.IsNotExist(err))
+		}
+		assert.Nil(t, os.Remove(hintFile(id)+".tmp"))
+	}
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+	for i := 0; i < 50; i++ {
+		val, err := db.Get([]byte(fmt.Sprintf("key-%03d", i)))
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("val-%05d", 950+i), string(val))
+	}
+}
+
+func TestFastDB_SingleReclaimBatch(t *testing.T) {
+	db := initReclaimDb(t, KeyOnlyMemMode)
+	db.config.SingleReclaimThreshold = 1024
+
+	for i := 0; db.activeFile[String].Offset < db.config.BlockSize-1024; i++ {
+		_ = db.Set([]byte(fmt.Sprintf("key-%03d", i)), []byte("val"))
+	}
+	// the entries of the batch are in two files, and the commit entry is in the second one.
+	batchFile := db.activeFileIds[String]
+	wb := db.NewWriteBatch()
+	for i := 0; i < 20; i++ {
+		_ = wb.Set([]byte(fmt.Sprintf("batch-%02d", i)), make([]byte, 100))
+	}
+	assert.Nil(t, wb.Commit())
+	commitFile := db.activeFileIds[String]
+	assert.Equal(t, batchFile+1, commitFile)
+
+	// only the file of the commit entry is reclaimed.
+	for i := 0; db.activeFileIds[String] == commitFile; i++ {
+		_ = db.Set([]byte("junk"), []byte(fmt.Sprintf("junk-%05d", i)))
+	}
+	db.meta.ReclaimableSpace[batchFile] = 0
+	assert.Nil(t, db.SingleReclaim())
+	assert.True(t, db.meta.ReclaimableSpace[commitFile] < 1024)
+	// the commit entry is still kept when reclaiming the file again.
+	db.meta.ReclaimableSpace[commitFile] = 1024
+	assert.Nil(t, db.SingleReclaim())
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+	for i := 0; i < 20; i++ {
+		val, err := db.Get([]byte(fmt.Sprintf("batch-%02d", i)))
+		assert.Nil(t, err)
+		assert.Equal(t, make([]byte, 100), val)
+	}
+}
+
+func TestFastDB_SingleReclaimInBackground(t *testing.T) {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.MaxKeySize, config.MaxValueSize, config.BlockSize = 64, 1024, 4*1024
+	config.SingleReclaimThreshold = 1024
+	config.SingleReclaimInterval = 10 * time.Millisecond
+	config.Sync = false
+
+	db, err := Open(config)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		_ = db.Set([]byte("key"), []byte(fmt.Sprintf("val-%05d", i)))
+	}
+
+	reclaimed := func() bool {
+		db.strIndex.mu.RLock()
+		defer db.strIndex.mu.RUnlock()
+		return db.meta.ReclaimableSpace[0] < config.SingleReclaimThreshold
+	}
+	assert.Eventually(t, reclaimed, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,225 @@
+package fastdb
+
+import (
+	"errors"
+	"fastdb/storage"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initRecoverDb(t *testing.T, method storage.FileRWMethod) *FastDB {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.RwMethod = method
+	config.MaxValueSize, config.BlockSize = 16*1024, 64*1024
+
+	db, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// close the db, then write the data to the active String file at offset, like a crash happened while writing.
+func writeAfterClose(t *testing.T, db *FastDB, offset int64, data []byte) {
+	path := db.config.DirPath + storage.PathSeparator + fmt.Sprintf(storage.DBFileFormatNames[String], db.activeFileIds[String])
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, storage.FilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(data, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFastDB_RecoverTornWrite(t *testing.T) {
+	encoded, _ := storage.NewEntryNoExtra([]byte("k3"), []byte("v3"), String, StringSet).Encode()
+	badCrc := append([]byte{}, encoded...)
+	badCrc[len(badCrc)-1] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"torn header", encoded[:10]},
+		{"torn value", encoded[:len(encoded)-1]},
+		{"invalid crc", badCrc},
+	}
+	for _, method := range []storage.FileRWMethod{storage.FileIO, storage.MMap} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s %d", tt.name, method), func(t *testing.T) {
+				db := initRecoverDb(t, method)
+				_ = db.Set([]byte("k1"), []byte("v1"))
+				_ = db.Set([]byte("k2"), []byte("v2"))
+				offset := db.activeFile[String].Offset
+				path := db.config.DirPath
+				writeAfterClose(t, db, offset, tt.data)
+
+				db, err := Reopen(path)
+				assert.Nil(t, err)
+				assert.Equal(t, offset, db.activeFile[String].Offset)
+				assert.False(t, db.StrExists([]byte("k3")))
+				if method == storage.FileIO {
+					info, _ := os.Stat(path + storage.PathSeparator + fmt.Sprintf(storage.DBFileFormatNames[String], 0))
+					assert.Equal(t, offset, info.Size())
+				}
+
+				// the new writes are appended after the valid entries.
+				_ = db.Set([]byte("k4"), []byte("v4"))
+				db = CloseAndReopen(t, db)
+				defer db.Close()
+				for _, k := range []string{"k1", "k2", "k4"} {
+					val, err := db.Get([]byte(k))
+					assert.Nil(t, err)
+					assert.Equal(t, "v"+k[1:], string(val))
+				}
+			})
+		}
+	}
+}
+
+func TestFastDB_RecoverCorrupted(t *testing.T) {
+	for _, method := range []storage.FileRWMethod{storage.FileIO, storage.MMap} {
+		db := initRecoverDb(t, method)
+		_ = db.Set([]byte("k1"), []byte("v1"))
+		_ = db.Set([]byte("k2"), []byte("v2"))
+		path := db.config.DirPath
+
+		// break the value of the first entry, the entries after it are still valid.
+		writeAfterClose(t, db, storage.FileHeaderSize+int64(storage.NewEntryNoExtra([]byte("k1"), []byte("v1"), String, StringSet).Size())-1, []byte("x"))
+
+		_, err := Reopen(path)
+		assert.True(t, errors.Is(err, ErrDBFileCorrupted))
+	}
+}
+
+func TestFastDB_RecoverBrokenSize(t *testing.T) {
+	for _, method := range []storage.FileRWMethod{storage.FileIO, storage.MMap} {
+		db := initRecoverDb(t, method)
+		_ = db.Set([]byte("k1"), []byte("v1"))
+		_ = db.Set([]byte("k2"), []byte("v2"))
+		path := db.config.DirPath
+
+		// the value size of the first entry runs past the end of the file, the entries after it are not truncated.
+		writeAfterClose(t, db, storage.FileHeaderSize+8, []byte{0, 1, 0, 0})
+
+		_, err := Reopen(path)
+		assert.True(t, errors.Is(err, ErrDBFileCorrupted))
+	}
+}
+
+func TestFastDB_RecoverWriteOff(t *testing.T) {
+	for _, method := range []storage.FileRWMethod{storage.FileIO, storage.MMap} {
+		db := initRecoverDb(t, method)
+		_ = db.Set([]byte("k1"), []byte("v1"))
+		_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+		path := db.config.DirPath
+		offset := db.activeFile[String].Offset
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the meta is stale after a crash.
+		stale := &storage.DBMeta{ActiveWriteOff: map[uint16]int64{String: 0, Hash: 1024}, ReclaimableSpace: map[uint32]int64{}}
+		assert.Nil(t, stale.Store(path+dbMetaSaveFile))
+
+		db, err := Reopen(path)
+		assert.Nil(t, err)
+		assert.Equal(t, offset, db.activeFile[String].Offset)
+
+		// the new writes don`t overwrite the valid entries.
+		_ = db.Set([]byte("k2"), []byte("v2"))
+		_, _ = db.HSet([]byte("h1"), []byte("f2"), []byte("v2"))
+		db = CloseAndReopen(t, db)
+		val, _ := db.Get([]byte("k1"))
+		assert.Equal(t, []byte("v1"), val)
+		val, _ = db.Get([]byte("k2"))
+		assert.Equal(t, []byte("v2"), val)
+		assert.Equal(t, []byte("v1"), db.HGet([]byte("h1"), []byte("f1")))
+		assert.Equal(t, []byte("v2"), db.HGet([]byte("h1"), []byte("f2")))
+		_ = db.Close()
+	}
+}
+
+func TestFastDB_RecoverTornFileHeader(t *testing.T) {
+	// a header whose crc doesn`t match.
+	badCrc := []byte("FSDB\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00")
+	tests := []struct {
+		name   string
+		method storage.FileRWMethod
+		data   []byte
+		size   int64
+	}{
+		{"short header file io", storage.FileIO, []byte("FSDB\x00\x01\x00"), 7},
+		{"short header mmap", storage.MMap, []byte("FSDB\x00\x01\x00"), 7},
+		{"zero mmap", storage.MMap, nil, 64 * 1024},
+		{"half header mmap", storage.MMap, []byte("FSDB"), 64 * 1024},
+		{"bad crc file io", storage.FileIO, badCrc, storage.FileHeaderSize},
+		{"bad crc mmap", storage.MMap, badCrc, 64 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := initRecoverDb(t, tt.method)
+			for i := 0; db.activeFileIds[String] == 0; i++ {
+				_ = db.Set([]byte(fmt.Sprintf("k%d", i)), make([]byte, 1024))
+			}
+			_ = db.Set([]byte("last"), []byte("v1"))
+			path := db.config.DirPath
+			activeFileId := db.activeFileIds[String]
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// crash while creating a new active file, only a part of the header is written.
+			file := path + storage.PathSeparator + fmt.Sprintf(storage.DBFileFormatNames[String], activeFileId+1)
+			assert.Nil(t, os.WriteFile(file, tt.data, storage.FilePerm))
+			assert.Nil(t, os.Truncate(file, tt.size))
+
+			db, err := Reopen(path)
+			assert.Nil(t, err)
+			assert.Equal(t, activeFileId+1, db.activeFileIds[String])
+			assert.Equal(t, int64(storage.FileHeaderSize), db.activeFile[String].Offset)
+			val, err := db.Get([]byte("last"))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("v1"), val)
+
+			_ = db.Set([]byte("new"), []byte("v2"))
+			db = CloseAndReopen(t, db)
+			defer db.Close()
+			val, err = db.Get([]byte("new"))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("v2"), val)
+			assert.True(t, db.StrExists([]byte("k0")))
+		})
+	}
+}
+
+func TestFastDB_RecoverBadFileHeader(t *testing.T) {
+	db := initRecoverDb(t, storage.FileIO)
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	path := db.config.DirPath
+	file := path + storage.PathSeparator + fmt.Sprintf(storage.DBFileFormatNames[String], db.activeFileIds[String])
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the header is broken, but the entries follow it, so the file is not emptied.
+	f, err := os.OpenFile(file, os.O_RDWR, storage.FilePerm)
+	assert.Nil(t, err)
+	_, _ = f.WriteAt([]byte{0xff}, 10)
+	_ = f.Close()
+	before, _ := os.Stat(file)
+
+	_, err = Reopen(path)
+	assert.True(t, errors.Is(err, storage.ErrInvalidFileHeader))
+	after, _ := os.Stat(file)
+	assert.Equal(t, before.Size(), after.Size())
+}
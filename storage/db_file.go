@@ -3,12 +3,13 @@ package storage
 import (
 	"errors"
 	"fmt"
-	"hash/crc32"
+	"io"
 	"io/ioutil"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/roseduan/mmap-go"
 )
@@ -40,6 +41,9 @@ var (
 var (
 	// ErrEmptyEntry the entry is empty
 	ErrEmptyEntry = errors.New("storage/db_file: entry or the Key of entry is empty")
+
+	// ErrTruncatedEntry the entry exceeds the end of the file
+	ErrTruncatedEntry = errors.New("storage/db_file: entry is truncated")
 )
 
 // FileRWMethod 文件数据读写的方式
@@ -61,10 +65,14 @@ const (
 type DBFile struct {
 	Id     uint32
 	path   string
+	Header *FileHeader
 	File   *os.File
 	mmap   mmap.MMap
 	Offset int64
 	method FileRWMethod
+
+	// the max size of the file, no entry exceeds it.
+	blockSize int64
 }
 
 func (df *DBFile) Write(e *Entry) error {
@@ -94,14 +102,24 @@ func (df *DBFile) Write(e *Entry) error {
 
 func (df *DBFile) Read(offset int64) (e *Entry, err error) {
 	var buf []byte
-	if buf, err = df.readBuf(offset, int64(entryHeaderSize)); err != nil {
+	if buf, err = df.readBuf(offset, int64(EntryHeaderSize)); err != nil {
 		return
 	}
 	if e, err = Decode(buf); err != nil {
 		return
 	}
+	// an entry never exceeds the block, a broken header may have a very large size.
+	if e.Meta.KeySize > 0 && offset+int64(e.Size()) > df.blockSize {
+		return nil, ErrTruncatedEntry
+	}
+	// the end of the file is only expected before the header.
+	defer func() {
+		if err == io.EOF {
+			e, err = nil, ErrTruncatedEntry
+		}
+	}()
 	//log.Println(e.Meta.ExtraSize)
-	offset += entryHeaderSize
+	offset += EntryHeaderSize
 	if e.Meta.KeySize > 0 {
 		var key []byte
 		if key, err = df.readBuf(offset, int64(e.Meta.KeySize)); err != nil {
@@ -129,8 +147,7 @@ func (df *DBFile) Read(offset int64) (e *Entry, err error) {
 	}
 	//log.Println(e.Meta.ValueSize)
 	//log.Println(string(e.Meta.Value))
-	checkCrc := crc32.ChecksumIEEE(e.Meta.Value)
-	if checkCrc != e.crc32 {
+	if !e.validCrc(buf) {
 		return nil, ErrInvalidCrc
 	}
 	return
@@ -140,7 +157,10 @@ func (df *DBFile) Read(offset int64) (e *Entry, err error) {
 func (df *DBFile) readBuf(offset int64, n int64) ([]byte, error) {
 	buf := make([]byte, n)
 	if df.method == FileIO {
-		_, err := df.File.ReadAt(buf, offset)
+		nr, err := df.File.ReadAt(buf, offset)
+		if err == io.EOF && nr > 0 {
+			err = ErrTruncatedEntry
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -154,6 +174,97 @@ func (df *DBFile) readBuf(offset int64, n int64) ([]byte, error) {
 	return buf, nil
 }
 
+// the size of the file, it is the block size in MMap.
+func (df *DBFile) size() (int64, error) {
+	if df.method == MMap {
+		return int64(len(df.mmap)), nil
+	}
+	info, err := df.File.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// IsTail check if nothing is written after the entry at offset, the entry may be broken.
+// A broken entry at the tail of the active file is a torn write caused by a crash, otherwise the file is corrupted.
+func (df *DBFile) IsTail(offset int64) (bool, error) {
+	size, err := df.size()
+	if err != nil {
+		return false, err
+	}
+	buf, err := df.readBuf(offset, int64(EntryHeaderSize))
+	if err == io.EOF || err == ErrTruncatedEntry {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	e, err := Decode(buf)
+	if err != nil {
+		return false, err
+	}
+
+	// the size of a torn entry is still valid, the file ends before the entry or nothing is written after it.
+	end := offset + int64(e.Size())
+	if end > df.blockSize {
+		return false, nil
+	}
+	if end >= size {
+		return true, nil
+	}
+	return df.IsZero(end)
+}
+
+// IsZero check if all the data from offset to the end of the file are zero, it is the unused tail of a db file in MMap.
+func (df *DBFile) IsZero(offset int64) (bool, error) {
+	size, err := df.size()
+	if err != nil || offset >= size {
+		return err == nil, err
+	}
+	rest, err := df.readBuf(offset, size-offset)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range rest {
+		if b != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Truncate drop the data after offset, returns the size of the dropped data.
+// The size of the file is fixed in MMap, so the data are zeroed instead.
+func (df *DBFile) Truncate(offset int64) (dropped int64, err error) {
+	if df.method == MMap {
+		end := int64(len(df.mmap))
+		for end > offset && df.mmap[end-1] == 0 {
+			end--
+		}
+		if end <= offset {
+			return 0, nil
+		}
+		dropped = end - offset
+		copy(df.mmap[offset:end], make([]byte, dropped))
+	} else {
+		var size int64
+		if size, err = df.size(); err != nil || size <= offset {
+			return
+		}
+		if err = df.File.Truncate(offset); err != nil {
+			return
+		}
+		dropped = size - offset
+	}
+
+	if df.Offset > offset {
+		df.Offset = offset
+	}
+	err = df.Sync()
+	return
+}
+
 //根据不同的数据类型新建数据库文件
 func NewDBFile(path string, fileId uint32, method FileRWMethod, blockSize int64, eType uint16) (*DBFile, error) {
 	filePath := path + PathSeparator + fmt.Sprintf(DBFileFormatNames[eType], fileId)
@@ -163,7 +274,26 @@ func NewDBFile(path string, fileId uint32, method FileRWMethod, blockSize int64,
 		return nil, err
 	}
 
-	df := &DBFile{Id: fileId, path: path, Offset: 0, method: method}
+	// write the header if the file is newly created, otherwise check it.
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var header *FileHeader
+	if info.Size() == 0 {
+		header = &FileHeader{Version: FormatVersion, DataType: eType, CreatedAt: time.Now().UnixNano()}
+		if _, err = file.WriteAt(header.encode(), 0); err != nil {
+			return nil, err
+		}
+		if err = file.Sync(); err != nil {
+			return nil, err
+		}
+	} else if header, err = readFileHeader(file, eType); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w, file: %s", err, filePath)
+	}
+
+	df := &DBFile{Id: fileId, path: path, Header: header, Offset: FileHeaderSize, method: method, blockSize: blockSize}
 
 	if method == FileIO {
 		df.File = file
@@ -193,12 +323,11 @@ func (df *DBFile) Sync() (err error) {
 	return
 }
 
-// Build 加载数据文件
-// build db files.
-func Build(path string, method FileRWMethod, blockSize int64) (map[uint16]map[uint32]*DBFile, map[uint16]uint32, error) {
+// FileIds returns the ids of the db files in path, grouped by the data type.
+func FileIds(path string) (map[uint16][]int, error) {
 	dir, err := ioutil.ReadDir(path)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	fileIdsMap := make(map[uint16][]int)
@@ -222,6 +351,19 @@ func Build(path string, method FileRWMethod, blockSize int64) (map[uint16]map[ui
 			}
 		}
 	}
+	for _, ids := range fileIdsMap {
+		sort.Ints(ids)
+	}
+	return fileIdsMap, nil
+}
+
+// Build 加载数据文件
+// build db files.
+func Build(path string, method FileRWMethod, blockSize int64) (map[uint16]map[uint32]*DBFile, map[uint16]uint32, error) {
+	fileIdsMap, err := FileIds(path)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// load all the db files.
 	activeFileIds := make(map[uint16]uint32)
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"encoding/binary"
+	"hash/crc32"
 	"log"
 	"os"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -79,7 +83,46 @@ func TestDBFile_Read(t *testing.T) {
 			t.Log(string(e.Meta.Key), e.Meta.KeySize, string(e.Meta.Value), e.Meta.ValueSize, e.crc32)
 		}
 	}
-	readEntry(0)
-	readEntry(50)
-	readEntry(100)
+	readEntry(FileHeaderSize)
+	readEntry(FileHeaderSize + 50)
+	readEntry(FileHeaderSize + 100)
+}
+
+func TestDBFile_ReadCrc(t *testing.T) {
+	path := t.TempDir()
+	df, err := NewDBFile(path, 0, FileIO, defaultBlockSize, String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer df.Close(false)
+
+	e := NewEntry([]byte("key"), []byte("val"), []byte("extra"), String, 1)
+	assert.Nil(t, df.Write(e))
+
+	// the entry of the old format, its crc only covers the value.
+	old, _ := e.Encode()
+	binary.BigEndian.PutUint16(old[16:18], e.state)
+	binary.BigEndian.PutUint32(old[0:4], crc32.ChecksumIEEE(e.Meta.Value))
+	_, _ = df.File.WriteAt(old, df.Offset)
+
+	for _, offset := range []int64{FileHeaderSize, FileHeaderSize + int64(e.Size())} {
+		r, err := df.Read(offset)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("key"), r.Meta.Key)
+		assert.Equal(t, []byte("extra"), r.Meta.Extra)
+		assert.Equal(t, String, r.GetType())
+		assert.Equal(t, uint16(1), r.GetMark())
+	}
+
+	// the corruption of the key or the header is found in the new format only.
+	_, _ = df.File.WriteAt([]byte("K"), FileHeaderSize+EntryHeaderSize)
+	_, _ = df.File.WriteAt([]byte("K"), FileHeaderSize+int64(e.Size())+EntryHeaderSize)
+	_, err = df.Read(FileHeaderSize)
+	assert.Equal(t, ErrInvalidCrc, err)
+	_, err = df.Read(FileHeaderSize + int64(e.Size()))
+	assert.Nil(t, err)
+
+	_, _ = df.File.WriteAt([]byte{1}, FileHeaderSize+16)
+	_, err = df.Read(FileHeaderSize)
+	assert.Equal(t, ErrInvalidCrc, err)
 }
@@ -32,7 +32,7 @@ func LoadMeta(path string) (m *DBMeta) {
 
 // Store store db meta as json.
 func (m *DBMeta) Store(path string) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
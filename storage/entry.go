@@ -8,8 +8,8 @@ import (
 )
 
 const (
-	// 4 * 4 + 8 + 2 = 26
-	entryHeaderSize = 26
+	// EntryHeaderSize the size of the header of an entry, 4 * 4 + 8 + 2 = 26.
+	EntryHeaderSize = 26
 )
 
 var (
@@ -18,6 +18,21 @@ var (
 	ErrInvalidCrc = errors.New("storage/entry: invalid crc")
 )
 
+const (
+	// batchFlag marks the entry as a part of a write batch, it is the highest bit of the state.
+	batchFlag uint16 = 1 << 15
+
+	// crcFlag marks the entry whose crc covers the header, key, value and extra.
+	// The crc of the entries written by the old versions only covers the value, they are still readable.
+	crcFlag uint16 = 1 << 14
+
+	// BatchCommitMark the mark of the commit entry of a write batch, it is not used by any operation of the data types.
+	BatchCommitMark uint16 = 0xff
+)
+
+// the key of the commit entry, the entry with an empty key can`t be written.
+var batchCommitKey = []byte("batch")
+
 const (
 	String uint16 = iota
 	List
@@ -57,7 +72,7 @@ func newInternal(key, value, extra []byte, state uint16, timestamp uint64) *Entr
 }
 
 func (e *Entry) Size() uint32 {
-	return entryHeaderSize + e.Meta.KeySize + e.Meta.ValueSize + e.Meta.ExtraSize
+	return EntryHeaderSize + e.Meta.KeySize + e.Meta.ValueSize + e.Meta.ExtraSize
 }
 
 func (e *Entry) Encode() ([]byte, error) {
@@ -69,19 +84,20 @@ func (e *Entry) Encode() ([]byte, error) {
 	es := e.Meta.ExtraSize
 	buf := make([]byte, e.Size())
 
-	crc := crc32.ChecksumIEEE(e.Meta.Value)
-	binary.BigEndian.PutUint32(buf[0:4], crc)
 	binary.BigEndian.PutUint32(buf[4:8], ks)
 	binary.BigEndian.PutUint32(buf[8:12], vs)
 	binary.BigEndian.PutUint32(buf[12:16], es)
-	binary.BigEndian.PutUint16(buf[16:18], e.state)
+	binary.BigEndian.PutUint16(buf[16:18], e.state|crcFlag)
 	binary.BigEndian.PutUint64(buf[18:26], e.Timestamp)
-	copy(buf[entryHeaderSize:entryHeaderSize+ks], e.Meta.Key)
-	copy(buf[entryHeaderSize+ks:(entryHeaderSize+ks+vs)], e.Meta.Value)
+	copy(buf[EntryHeaderSize:EntryHeaderSize+ks], e.Meta.Key)
+	copy(buf[EntryHeaderSize+ks:(EntryHeaderSize+ks+vs)], e.Meta.Value)
 	if es > 0 {
-		copy(buf[(entryHeaderSize+ks+vs):(entryHeaderSize+ks+vs+es)], e.Meta.Extra)
+		copy(buf[(EntryHeaderSize+ks+vs):(EntryHeaderSize+ks+vs+es)], e.Meta.Extra)
 	}
 
+	// the crc covers all the bytes after it.
+	crc := crc32.ChecksumIEEE(buf[4:])
+	binary.BigEndian.PutUint32(buf[0:4], crc)
 	return buf, nil
 
 }
@@ -107,13 +123,26 @@ func Decode(buf []byte) (*Entry, error) {
 }
 
 func (e *Entry) GetType() uint16 {
-	return e.state >> 8
+	return (e.state &^ (batchFlag | crcFlag)) >> 8
 }
 
 func (e *Entry) GetMark() uint16 {
 	return e.state & (2<<7 - 1)
 }
 
+// check the crc of the entry read from the db file, header is the encoded header of the entry.
+func (e *Entry) validCrc(header []byte) bool {
+	if e.state&crcFlag == 0 {
+		return crc32.ChecksumIEEE(e.Meta.Value) == e.crc32
+	}
+
+	crc := crc32.ChecksumIEEE(header[4:EntryHeaderSize])
+	crc = crc32.Update(crc, crc32.IEEETable, e.Meta.Key)
+	crc = crc32.Update(crc, crc32.IEEETable, e.Meta.Value)
+	crc = crc32.Update(crc, crc32.IEEETable, e.Meta.Extra)
+	return crc == e.crc32
+}
+
 func NewEntryWithExpire(key, value []byte, deadline int64, t, mark uint16) *Entry {
 	var state uint16 = 0
 	// set type and mark.
@@ -136,3 +165,32 @@ func NewEntry(key, value, extra []byte, t, mark uint16) *Entry {
 func NewEntryNoExtra(key, value []byte, t, mark uint16) *Entry {
 	return NewEntry(key, value, nil, t, mark)
 }
+
+// NewBatchEntry create a new entry which belongs to a write batch, the batch id is saved as the timestamp.
+func NewBatchEntry(key, value, extra []byte, t, mark uint16, batchId uint64) *Entry {
+	state := batchFlag | (t << 8) | mark
+	return newInternal(key, value, extra, state, batchId)
+}
+
+// NewBatchCommitEntry create the commit entry of a write batch, the entries of the batch before it will take effect.
+func NewBatchCommitEntry(t uint16, batchId uint64) *Entry {
+	return NewBatchEntry(batchCommitKey, nil, nil, t, BatchCommitMark, batchId)
+}
+
+// BatchId returns the id of the write batch which the entry belongs to, ok is false if the entry is not in a batch.
+func (e *Entry) BatchId() (id uint64, ok bool) {
+	if e.state&batchFlag == 0 {
+		return 0, false
+	}
+	return e.Timestamp, true
+}
+
+// IsBatchCommit check if the entry is the commit entry of a write batch.
+func (e *Entry) IsBatchCommit() bool {
+	return e.state&batchFlag != 0 && e.GetMark() == BatchCommitMark
+}
+
+// Unbatch remove the batch flag, so the entry will take effect by itself.
+func (e *Entry) Unbatch() {
+	e.state &^= batchFlag
+}
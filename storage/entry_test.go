@@ -30,6 +30,25 @@ func TestEntry_GetMark(t *testing.T) {
 	assert.Equal(t, e.GetMark(), uint16(15))
 }
 
+func TestNewBatchEntry(t *testing.T) {
+	e := NewBatchEntry([]byte("key"), []byte("val"), nil, Hash, 1, 1024)
+	assert.Equal(t, Hash, e.GetType())
+	assert.Equal(t, uint16(1), e.GetMark())
+	id, ok := e.BatchId()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1024), id)
+	assert.False(t, e.IsBatchCommit())
+
+	e.Unbatch()
+	_, ok = e.BatchId()
+	assert.False(t, ok)
+	assert.Equal(t, Hash, e.GetType())
+
+	c := NewBatchCommitEntry(ZSet, 1024)
+	assert.Equal(t, ZSet, c.GetType())
+	assert.True(t, c.IsBatchCommit())
+}
+
 func TestEntry_Encode(t *testing.T) {
 	//正常key和value的情况
 	t.Run("test1", func(t *testing.T) {
@@ -82,7 +101,7 @@ func TestDecode(t *testing.T) {
 	if file, err := os.OpenFile("/tmp/fastdb_test.dat", os.O_RDONLY, os.ModePerm); err != nil {
 		t.Error("open File err ", err)
 	} else {
-		buf := make([]byte, entryHeaderSize)
+		buf := make([]byte, EntryHeaderSize)
 		var offset int64 = 0
 		if n, err := file.ReadAt(buf, offset); err != nil {
 			t.Error("read data err ", err)
@@ -93,7 +112,7 @@ func TestDecode(t *testing.T) {
 			e, _ := Decode(buf)
 
 			//read key
-			offset += entryHeaderSize
+			offset += EntryHeaderSize
 			if e.Meta.KeySize > 0 {
 				key := make([]byte, e.Meta.KeySize)
 				file.ReadAt(key, offset)
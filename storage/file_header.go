@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+const (
+	// FileHeaderSize the size of the header at the beginning of every db file, the first entry is written after it.
+	// magic(4) + version(2) + data type(2) + creation time(8) + crc(4) = 20
+	FileHeaderSize = 20
+
+	// FormatVersion the current version of the db file format.
+	// The files of version 0 have no file header, they can be upgraded by the fastdb-migrate command.
+	FormatVersion uint16 = 1
+)
+
+var fileMagic = []byte("FSDB")
+
+var (
+	// ErrInvalidFileHeader the file header is broken or missing
+	ErrInvalidFileHeader = errors.New("storage/file_header: invalid file header, the file may be written by an old version, run fastdb-migrate to upgrade it")
+
+	// ErrUnsupportedVersion the format version of the file is not supported
+	ErrUnsupportedVersion = errors.New("storage/file_header: unsupported format version")
+)
+
+// FileHeader the header of a db file.
+type FileHeader struct {
+	Version   uint16
+	DataType  uint16
+	CreatedAt int64 // unix nano
+}
+
+func (h *FileHeader) encode() []byte {
+	buf := make([]byte, FileHeaderSize)
+	copy(buf[0:4], fileMagic)
+	binary.BigEndian.PutUint16(buf[4:6], h.Version)
+	binary.BigEndian.PutUint16(buf[6:8], h.DataType)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(h.CreatedAt))
+	binary.BigEndian.PutUint32(buf[16:20], crc32.ChecksumIEEE(buf[:16]))
+	return buf
+}
+
+func decodeFileHeader(buf []byte) (*FileHeader, error) {
+	if len(buf) < FileHeaderSize || !bytes.Equal(buf[0:4], fileMagic) {
+		return nil, ErrInvalidFileHeader
+	}
+	if crc32.ChecksumIEEE(buf[:16]) != binary.BigEndian.Uint32(buf[16:20]) {
+		return nil, ErrInvalidFileHeader
+	}
+	return &FileHeader{
+		Version:   binary.BigEndian.Uint16(buf[4:6]),
+		DataType:  binary.BigEndian.Uint16(buf[6:8]),
+		CreatedAt: int64(binary.BigEndian.Uint64(buf[8:16])),
+	}, nil
+}
+
+// read and check the header of an existing db file.
+func readFileHeader(file *os.File, eType uint16) (*FileHeader, error) {
+	buf := make([]byte, FileHeaderSize)
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		return nil, ErrInvalidFileHeader
+	}
+	h, err := decodeFileHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+	if h.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+	if h.DataType != eType {
+		return nil, ErrInvalidFileHeader
+	}
+	return h, nil
+}
+
+// FileVersion returns the format version of the db file, the file without header is version 0.
+func FileVersion(path string, fileId uint32, eType uint16) (uint16, error) {
+	file, err := os.Open(path + PathSeparator + fmt.Sprintf(DBFileFormatNames[eType], fileId))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, FileHeaderSize)
+	if n, _ := file.ReadAt(buf, 0); n < FileHeaderSize || !bytes.Equal(buf[0:4], fileMagic) {
+		return 0, nil
+	}
+	h, err := decodeFileHeader(buf)
+	if err != nil {
+		return 0, err
+	}
+	return h.Version, nil
+}
+
+// OpenLegacyDBFile open a db file of version 0 for reading, its entries start at offset 0.
+func OpenLegacyDBFile(path string, fileId uint32, eType uint16, blockSize int64) (*DBFile, error) {
+	file, err := os.Open(path + PathSeparator + fmt.Sprintf(DBFileFormatNames[eType], fileId))
+	if err != nil {
+		return nil, err
+	}
+	return &DBFile{Id: fileId, path: path, File: file, method: FileIO, blockSize: blockSize}, nil
+}
+
+// RepairFileHeader empty the active db file if its header was never completely written, NewDBFile writes a new header for it then.
+// It happens when crashing while creating the file: the file is shorter than the header, or the header is partly written
+// and the rest of the file is zero in MMap, such as "FSDB" followed by zeros, so the crc of the header doesn`t match.
+// No entry can be written before the header, so the header is rewritten only if nothing follows it, and nothing is lost.
+// Returns true if the file is emptied.
+func RepairFileHeader(path string, fileId uint32, eType uint16) (bool, error) {
+	file, err := os.OpenFile(path+PathSeparator+fmt.Sprintf(DBFileFormatNames[eType], fileId), os.O_RDWR, FilePerm)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() == 0 {
+		return false, nil
+	}
+	if info.Size() >= FileHeaderSize {
+		buf := make([]byte, FileHeaderSize)
+		if _, err = file.ReadAt(buf, 0); err != nil {
+			return false, err
+		}
+		if _, err = decodeFileHeader(buf); err == nil {
+			return false, nil
+		}
+		if zero, err := isZero(file, FileHeaderSize); err != nil || !zero {
+			return false, err
+		}
+	}
+
+	if err = file.Truncate(0); err != nil {
+		return false, err
+	}
+	return true, file.Sync()
+}
+
+// check if all the bytes of the file from offset are zero.
+func isZero(file *os.File, offset int64) (bool, error) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.ReadAt(buf, offset)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		offset += int64(n)
+	}
+}
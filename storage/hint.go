@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// HintFileFormatName the name format of the hint files, only the archived String db files have hint files.
+	HintFileFormatName = "%09d.hint.str"
+
+	// crc(4) + key size(4) + file id(4) + offset(8) + entry size(4) + value size(4) + timestamp(8) + state(2) = 38
+	hintHeaderSize = 38
+)
+
+var (
+	// ErrInvalidHint the hint file is broken or doesn`t match the db file
+	ErrInvalidHint = errors.New("storage/hint: invalid hint file")
+)
+
+// Hint the position of an entry in the db file, the String indexes can be built from the hints without reading the db files.
+type Hint struct {
+	Key       []byte
+	FileId    uint32
+	Offset    int64
+	EntrySize uint32
+	ValueSize uint32
+	Timestamp uint64
+	state     uint16
+}
+
+// NewHint create the hint of the entry written at offset of the db file.
+func NewHint(e *Entry, fileId uint32, offset int64) *Hint {
+	return &Hint{
+		Key:       e.Meta.Key,
+		FileId:    fileId,
+		Offset:    offset,
+		EntrySize: e.Size(),
+		ValueSize: e.Meta.ValueSize,
+		Timestamp: e.Timestamp,
+		state:     e.state,
+	}
+}
+
+// Entry returns the entry of the hint without value and extra info.
+func (h *Hint) Entry() *Entry {
+	return &Entry{
+		Meta:      &Meta{Key: h.Key, KeySize: uint32(len(h.Key)), ValueSize: h.ValueSize},
+		state:     h.state,
+		Timestamp: h.Timestamp,
+	}
+}
+
+func (h *Hint) encode() []byte {
+	buf := make([]byte, hintHeaderSize+len(h.Key))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(h.Key)))
+	binary.BigEndian.PutUint32(buf[8:12], h.FileId)
+	binary.BigEndian.PutUint64(buf[12:20], uint64(h.Offset))
+	binary.BigEndian.PutUint32(buf[20:24], h.EntrySize)
+	binary.BigEndian.PutUint32(buf[24:28], h.ValueSize)
+	binary.BigEndian.PutUint64(buf[28:36], h.Timestamp)
+	binary.BigEndian.PutUint16(buf[36:38], h.state)
+	copy(buf[hintHeaderSize:], h.Key)
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// WriteHintFile write the hints of the db file, the header of the db file is saved to check if the hint file matches it.
+// The hint file is written to a temporary file first, then renamed, so a broken hint file is never seen.
+func WriteHintFile(path string, fileId uint32, header *FileHeader, hints []*Hint) error {
+	buf := header.encode()
+	for _, h := range hints {
+		buf = append(buf, h.encode()...)
+	}
+	// the crc of the whole file, so a truncated hint file can be found.
+	buf = append(buf, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], crc32.ChecksumIEEE(buf[:len(buf)-4]))
+
+	name := path + PathSeparator + fmt.Sprintf(HintFileFormatName, fileId)
+	file, err := os.OpenFile(name+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(buf); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+// ReadHintFile read the hints of the db file, returns ErrInvalidHint if the hint file is broken or written for another db file.
+func ReadHintFile(path string, fileId uint32, header *FileHeader) ([]*Hint, error) {
+	buf, err := ioutil.ReadFile(path + PathSeparator + fmt.Sprintf(HintFileFormatName, fileId))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < FileHeaderSize+4 || crc32.ChecksumIEEE(buf[:len(buf)-4]) != binary.BigEndian.Uint32(buf[len(buf)-4:]) {
+		return nil, ErrInvalidHint
+	}
+	h, err := decodeFileHeader(buf)
+	if err != nil || header == nil || *h != *header {
+		return nil, ErrInvalidHint
+	}
+
+	var hints []*Hint
+	buf = buf[FileHeaderSize : len(buf)-4]
+	for len(buf) > 0 {
+		if len(buf) < hintHeaderSize {
+			return nil, ErrInvalidHint
+		}
+		ks := int(binary.BigEndian.Uint32(buf[4:8]))
+		if len(buf) < hintHeaderSize+ks || crc32.ChecksumIEEE(buf[4:hintHeaderSize+ks]) != binary.BigEndian.Uint32(buf[0:4]) {
+			return nil, ErrInvalidHint
+		}
+		key := make([]byte, ks)
+		copy(key, buf[hintHeaderSize:])
+		hints = append(hints, &Hint{
+			Key:       key,
+			FileId:    binary.BigEndian.Uint32(buf[8:12]),
+			Offset:    int64(binary.BigEndian.Uint64(buf[12:20])),
+			EntrySize: binary.BigEndian.Uint32(buf[20:24]),
+			ValueSize: binary.BigEndian.Uint32(buf[24:28]),
+			Timestamp: binary.BigEndian.Uint64(buf[28:36]),
+			state:     binary.BigEndian.Uint16(buf[36:38]),
+		})
+		buf = buf[hintHeaderSize+ks:]
+	}
+	return hints, nil
+}
+
+// RemoveHintFile remove the hint file of the db file if it exists.
+func RemoveHintFile(path string, fileId uint32) error {
+	err := os.Remove(path + PathSeparator + fmt.Sprintf(HintFileFormatName, fileId))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHintFile(t *testing.T) {
+	path := t.TempDir()
+	header := &FileHeader{Version: FormatVersion, DataType: String, CreatedAt: 1024}
+
+	e1 := NewEntryNoExtra([]byte("k1"), []byte("v1"), String, 0)
+	e2 := NewBatchEntry([]byte("k2"), []byte("value2"), nil, String, 1, 10)
+	hints := []*Hint{NewHint(e1, 3, FileHeaderSize), NewHint(e2, 3, FileHeaderSize+int64(e1.Size()))}
+	assert.Nil(t, WriteHintFile(path, 3, header, hints))
+
+	res, err := ReadHintFile(path, 3, header)
+	assert.Nil(t, err)
+	assert.Equal(t, hints, res)
+	e := res[1].Entry()
+	assert.Equal(t, []byte("k2"), e.Meta.Key)
+	assert.Equal(t, uint32(6), e.Meta.ValueSize)
+	assert.Equal(t, uint16(1), e.GetMark())
+	id, ok := e.BatchId()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), id)
+
+	// the hint file of another db file with the same id.
+	_, err = ReadHintFile(path, 3, &FileHeader{Version: FormatVersion, DataType: String, CreatedAt: 2048})
+	assert.Equal(t, ErrInvalidHint, err)
+
+	// the hint file is truncated.
+	name := path + PathSeparator + fmt.Sprintf(HintFileFormatName, 3)
+	b, _ := ioutil.ReadFile(name)
+	assert.Nil(t, ioutil.WriteFile(name, b[:len(b)-10], FilePerm))
+	_, err = ReadHintFile(path, 3, header)
+	assert.Equal(t, ErrInvalidHint, err)
+
+	assert.Nil(t, RemoveHintFile(path, 3))
+	assert.Nil(t, RemoveHintFile(path, 3))
+	_, err = ReadHintFile(path, 3, header)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,403 @@
+package fastdb
+
+import (
+	"fastdb/index"
+	"sync"
+	"time"
+)
+
+// Txn an optimistic read-write transaction.
+// The reads see the values as of the beginning of the transaction: the strings and hashes modified by others since then
+// are saved in the snapshots of the transaction before the first modification, and read from the snapshots.
+// The writes are buffered and committed atomically as a write batch, the id of the batch is saved in the entry header as the transaction id.
+// The commit fails with ErrTxnConflict if any key read by the transaction was modified by others, the conflicts are checked by key.
+// A Txn is not safe for concurrent use.
+type Txn struct {
+	db       *FastDB
+	readTs   uint64
+	reads    map[txnKey]struct{}
+	ops      []batchOp
+	strSnap  *strSnapshot
+	hashSnap *hashSnapshot
+
+	// the buffered writes for reading them in the transaction, a nil value means removed.
+	strWrites  map[string][]byte
+	hashWrites map[string]map[string][]byte
+}
+
+// hashSnapshot keeps the hashes modified after a transaction begins, all the fields before the first modification are saved.
+type hashSnapshot struct {
+	createdAt int64
+
+	// a nil *savedHash means the hash did not exist or was expired.
+	saved map[string]*savedHash
+}
+
+type savedHash struct {
+	fields map[string][]byte
+	err    error
+}
+
+// the key of a data type, for tracking the keys read and written.
+type txnKey struct {
+	dType DataType
+	key   string
+}
+
+// oracle tracks the keys written recently, for checking the conflicts of the running transactions.
+type oracle struct {
+	mu sync.Mutex
+
+	// increased by every written entry.
+	seq uint64
+
+	// the read timestamps of the running transactions.
+	active map[uint64]int
+
+	// the keys written after the oldest running transaction began, in the order of seq.
+	writes []txnWrite
+}
+
+type txnWrite struct {
+	seq uint64
+	key txnKey
+}
+
+func newOracle() *oracle {
+	return &oracle{active: make(map[uint64]int)}
+}
+
+// Txn run fn in a transaction, the transaction will be committed if fn returns nil, otherwise it will be discarded.
+//
+//	err := db.Txn(func(tx *Txn) error {
+//		val, err := tx.Get([]byte("balance"))
+//		if err != nil {
+//			return err
+//		}
+//		return tx.Set([]byte("balance"), incr(val))
+//	})
+func (db *FastDB) Txn(fn func(tx *Txn) error) error {
+	now := time.Now().Unix()
+	tx := &Txn{
+		db:         db,
+		reads:      make(map[txnKey]struct{}),
+		strSnap:    &strSnapshot{createdAt: now, saved: index.NewSkipList()},
+		hashSnap:   &hashSnapshot{createdAt: now, saved: make(map[string]*savedHash)},
+		strWrites:  make(map[string][]byte),
+		hashWrites: make(map[string]map[string][]byte),
+	}
+
+	// no string or hash is written while beginning, so the snapshots match the read timestamp.
+	db.strIndex.mu.Lock()
+	db.hashIndex.mu.Lock()
+	tx.readTs = db.oracle.begin()
+	db.strIndex.snapshots[tx.strSnap] = struct{}{}
+	db.hashIndex.snapshots[tx.hashSnap] = struct{}{}
+	db.hashIndex.mu.Unlock()
+	db.strIndex.mu.Unlock()
+	defer tx.close()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// Get get the value of key, the value written in the transaction will be returned first.
+func (tx *Txn) Get(key []byte) ([]byte, error) {
+	if err := tx.db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	if val, ok := tx.strWrites[string(key)]; ok {
+		if val == nil {
+			return nil, ErrKeyNotExist
+		}
+		return val, nil
+	}
+
+	db := tx.db
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	tx.read(String, key)
+	if e := tx.strSnap.saved.Get(key); e != nil {
+		s := e.Value().(*savedStr)
+		if s == nil {
+			return nil, ErrKeyNotExist
+		}
+		return s.value, s.err
+	}
+	return db.getVal(key)
+}
+
+// HGet get the value of field in the hash stored at key, the value written in the transaction will be returned first.
+func (tx *Txn) HGet(key, field []byte) ([]byte, error) {
+	if err := tx.db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	if fields, ok := tx.hashWrites[string(key)]; ok {
+		if val, ok := fields[string(field)]; ok {
+			return val, nil
+		}
+	}
+
+	db := tx.db
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	tx.read(Hash, key)
+	if s, ok := tx.hashSnap.saved[string(key)]; ok {
+		if s == nil {
+			return nil, nil
+		}
+		return s.fields[string(field)], s.err
+	}
+	if db.isExpired(key, Hash) {
+		return nil, nil
+	}
+	return db.hashVal(db.hashIndex.indexes.HGet(string(key), string(field)))
+}
+
+// Set set key to hold the string value when the transaction is committed.
+func (tx *Txn) Set(key, value []byte) error {
+	if err := tx.db.checkKeyValue(key, value); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, batchOp{dType: String, mark: StringSet, key: key, value: value})
+	tx.strWrites[string(key)] = value
+	return nil
+}
+
+// StrRem remove the string value stored at key when the transaction is committed.
+func (tx *Txn) StrRem(key []byte) error {
+	if err := tx.db.checkKeyValue(key, nil); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, batchOp{dType: String, mark: StringRem, key: key})
+	tx.strWrites[string(key)] = nil
+	return nil
+}
+
+// HSet set field in the hash stored at key to value when the transaction is committed.
+func (tx *Txn) HSet(key, field, value []byte) error {
+	if err := tx.db.checkKeyValue(key, value, field); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, batchOp{dType: Hash, mark: HashHSet, key: key, value: value, extra: field})
+	tx.hashWrite(key, field, value)
+	return nil
+}
+
+// HDel remove the specified fields from the hash stored at key when the transaction is committed.
+func (tx *Txn) HDel(key []byte, fields ...[]byte) error {
+	if err := tx.db.checkKeyValue(key, nil); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		tx.ops = append(tx.ops, batchOp{dType: Hash, mark: HashHDel, key: key, extra: f})
+		tx.hashWrite(key, f, nil)
+	}
+	return nil
+}
+
+func (tx *Txn) hashWrite(key, field, value []byte) {
+	if tx.hashWrites[string(key)] == nil {
+		tx.hashWrites[string(key)] = make(map[string][]byte)
+	}
+	tx.hashWrites[string(key)][string(field)] = value
+}
+
+// record the key read, its modifications by others are checked when committing.
+func (tx *Txn) read(dType DataType, key []byte) {
+	tx.reads[txnKey{dType: dType, key: string(key)}] = struct{}{}
+}
+
+func (tx *Txn) commit() error {
+	// the reads see the snapshots, so a read-only transaction is always consistent.
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	var readTypes []DataType
+	for k := range tx.reads {
+		readTypes = append(readTypes, k.dType)
+	}
+	return tx.db.commitBatch(tx.ops, readTypes, func() error {
+		if tx.db.oracle.modified(tx.readTs, tx.reads) {
+			return ErrTxnConflict
+		}
+		return nil
+	})
+}
+
+// remove the snapshots and finish the transaction.
+func (tx *Txn) close() {
+	db := tx.db
+	db.strIndex.mu.Lock()
+	delete(db.strIndex.snapshots, tx.strSnap)
+	db.strIndex.mu.Unlock()
+	db.hashIndex.mu.Lock()
+	delete(db.hashIndex.snapshots, tx.hashSnap)
+	db.hashIndex.mu.Unlock()
+	db.oracle.done(tx.readTs)
+}
+
+// save all the fields of the hash in the snapshots of the running transactions before it is modified.
+// It is only saved at the first modification, and the caller should hold the write lock of hashes.
+func (db *FastDB) saveHashSnapshots(key []byte) {
+	if len(db.hashIndex.snapshots) == 0 {
+		return
+	}
+
+	var s *savedHash
+	var deadline int64
+	var ttl, loaded bool
+	for snap := range db.hashIndex.snapshots {
+		if _, ok := snap.saved[string(key)]; ok {
+			continue
+		}
+		if !loaded {
+			if db.hashIndex.indexes.HKeyExists(string(key)) {
+				s = &savedHash{fields: make(map[string][]byte)}
+				all := db.hashIndex.indexes.HGetAll(string(key))
+				for i := 0; i < len(all) && s.err == nil; i += 2 {
+					s.fields[string(all[i])], s.err = db.hashVal(all[i+1])
+				}
+			}
+			deadline, ttl = db.expires[Hash][string(key)]
+			loaded = true
+		}
+
+		if s != nil && (!ttl || snap.createdAt <= deadline) {
+			snap.saved[string(key)] = s
+		} else {
+			snap.saved[string(key)] = nil
+		}
+	}
+}
+
+// begin a transaction, returns the read timestamp.
+func (o *oracle) begin() uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.active[o.seq]++
+	return o.seq
+}
+
+// returns the seq of the last written entry.
+func (o *oracle) current() uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.seq
+}
+
+// finish a transaction, and discard the writes which can`t conflict with any running transaction.
+func (o *oracle) done(readTs uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.active[readTs]--; o.active[readTs] <= 0 {
+		delete(o.active, readTs)
+	}
+
+	minTs := o.seq
+	for ts := range o.active {
+		if ts < minTs {
+			minTs = ts
+		}
+	}
+	i := 0
+	for i < len(o.writes) && o.writes[i].seq <= minTs {
+		i++
+	}
+	o.writes = o.writes[i:]
+	if len(o.writes) == 0 {
+		o.writes = nil
+	}
+}
+
+// record the key written, it is called for every written entry.
+func (o *oracle) record(dType DataType, key []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+	// no transaction is running, nobody cares about it.
+	if len(o.active) == 0 {
+		return
+	}
+	o.writes = append(o.writes, txnWrite{seq: o.seq, key: txnKey{dType: dType, key: string(key)}})
+}
+
+// check if any of the keys was written after readTs.
+func (o *oracle) modified(readTs uint64, keys map[txnKey]struct{}) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := len(o.writes) - 1; i >= 0 && o.writes[i].seq > readTs; i-- {
+		if _, ok := keys[o.writes[i].key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher reports whether the watched keys of any data type are modified after watching them.
+type Watcher struct {
+	db     *FastDB
+	readTs uint64
+	keys   map[string]uint64
+	closed bool
+}
+
+// Watch create a watcher of the keys, it must be closed after used.
+func (db *FastDB) Watch(keys ...[]byte) *Watcher {
+	w := &Watcher{
+		db:     db,
+		readTs: db.oracle.begin(),
+		keys:   make(map[string]uint64),
+	}
+	w.Add(keys...)
+	return w
+}
+
+// Add watch more keys, the modifications before adding them are ignored.
+func (w *Watcher) Add(keys ...[]byte) {
+	if w.closed {
+		return
+	}
+	seq := w.db.oracle.current()
+	for _, k := range keys {
+		if _, ok := w.keys[string(k)]; !ok {
+			w.keys[string(k)] = seq
+		}
+	}
+}
+
+// Modified check if any of the watched keys has been modified.
+func (w *Watcher) Modified() bool {
+	if w.closed {
+		return false
+	}
+	for k, seq := range w.keys {
+		keys := make(map[txnKey]struct{}, DataStructureNum)
+		for dType := String; dType < DataStructureNum; dType++ {
+			keys[txnKey{dType: dType, key: k}] = struct{}{}
+		}
+		if w.db.oracle.modified(seq, keys) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stop watching the keys.
+func (w *Watcher) Close() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.keys = nil
+	w.db.oracle.done(w.readTs)
+}
@@ -0,0 +1,199 @@
+package fastdb
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastDB_Txn(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	err := db.Txn(func(tx *Txn) error {
+		val, err := tx.Get([]byte("k1"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("v1"), val)
+
+		_ = tx.Set([]byte("k1"), []byte("v2"))
+		_ = tx.StrRem([]byte("k2"))
+		_ = tx.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+
+		// the writes are visible in the transaction only.
+		val, _ = tx.Get([]byte("k1"))
+		assert.Equal(t, []byte("v2"), val)
+		val, _ = tx.HGet([]byte("h1"), []byte("f1"))
+		assert.Equal(t, []byte("v1"), val)
+		assert.Nil(t, db.HGet([]byte("h1"), []byte("f1")))
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// the writes are discarded if fn returns an error.
+	errAbort := errors.New("abort")
+	err = db.Txn(func(tx *Txn) error {
+		_ = tx.Set([]byte("k1"), []byte("v3"))
+		return errAbort
+	})
+	assert.Equal(t, errAbort, err)
+
+	db = CloseAndReopen(t, db)
+	defer db.Close()
+	val, _ := db.Get([]byte("k1"))
+	assert.Equal(t, []byte("v2"), val)
+	assert.Equal(t, []byte("v1"), db.HGet([]byte("h1"), []byte("f1")))
+}
+
+func TestFastDB_TxnConflict(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_ = db.Set([]byte("k1"), []byte("v1"))
+
+	// the key read is modified before committing.
+	err := db.Txn(func(tx *Txn) error {
+		_, _ = tx.Get([]byte("k1"))
+		_ = db.Set([]byte("k1"), []byte("other"))
+		return tx.Set([]byte("k2"), []byte("v2"))
+	})
+	assert.Equal(t, ErrTxnConflict, err)
+	assert.False(t, db.StrExists([]byte("k2")))
+
+	// the key is modified before reading, the snapshot is read, but the commit still conflicts.
+	err = db.Txn(func(tx *Txn) error {
+		_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+		val, err := tx.HGet([]byte("h1"), []byte("f1"))
+		assert.Nil(t, err)
+		assert.Nil(t, val)
+		return tx.Set([]byte("k2"), []byte("v2"))
+	})
+	assert.Equal(t, ErrTxnConflict, err)
+	assert.False(t, db.StrExists([]byte("k2")))
+
+	// the writes of other keys don`t conflict.
+	err = db.Txn(func(tx *Txn) error {
+		_, _ = tx.Get([]byte("k1"))
+		_ = db.Set([]byte("k3"), []byte("v3"))
+		return tx.Set([]byte("k2"), []byte("v2"))
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, db.oracle.writes)
+}
+
+func TestFastDB_TxnSnapshot(t *testing.T) {
+	db := InitTestDb(t, KeyOnlyMemMode)
+	defer db.Close()
+
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	_ = db.SetEx([]byte("k2"), []byte("v2"), 100)
+	_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+	_, _ = db.HSet([]byte("h1"), []byte("f2"), []byte("v2"))
+
+	// the reads see the values as of the beginning, whatever others write.
+	err := db.Txn(func(tx *Txn) error {
+		_ = db.Set([]byte("k1"), []byte("other"))
+		_ = db.StrRem([]byte("k2"))
+		_ = db.Set([]byte("k3"), []byte("v3"))
+		_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("other"))
+		_, _ = db.HDel([]byte("h1"), []byte("f2"))
+
+		val, err := tx.Get([]byte("k1"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("v1"), val)
+		val, err = tx.Get([]byte("k2"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("v2"), val)
+		_, err = tx.Get([]byte("k3"))
+		assert.Equal(t, ErrKeyNotExist, err)
+		val, _ = tx.HGet([]byte("h1"), []byte("f1"))
+		assert.Equal(t, []byte("v1"), val)
+		val, _ = tx.HGet([]byte("h1"), []byte("f2"))
+		assert.Equal(t, []byte("v2"), val)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// the snapshots are removed after the transaction.
+	assert.Empty(t, db.strIndex.snapshots)
+	assert.Empty(t, db.hashIndex.snapshots)
+	val, _ := db.Get([]byte("k1"))
+	assert.Equal(t, []byte("other"), val)
+}
+
+func TestFastDB_TxnConcurrent(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_ = db.Set([]byte("counter"), []byte("0"))
+	incr := func(tx *Txn) error {
+		val, err := tx.Get([]byte("counter"))
+		if err != nil {
+			return err
+		}
+		n, _ := strconv.Atoi(string(val))
+		return tx.Set([]byte("counter"), []byte(strconv.Itoa(n+1)))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				// retry until there is no conflict.
+				for db.Txn(incr) == ErrTxnConflict {
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, _ := db.Get([]byte("counter"))
+	assert.Equal(t, "200", string(val))
+}
+
+func TestFastDB_Watch(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	w := db.Watch([]byte("k1"))
+	assert.False(t, w.Modified())
+
+	_ = db.Set([]byte("other"), []byte("v1"))
+	assert.False(t, w.Modified())
+
+	// the modification before adding the key is ignored.
+	_, _ = db.SAdd([]byte("k2"), []byte("m1"))
+	w.Add([]byte("k2"))
+	assert.False(t, w.Modified())
+
+	// the keys of all data types are watched.
+	_, _ = db.SAdd([]byte("k2"), []byte("m2"))
+	assert.True(t, w.Modified())
+
+	w.Close()
+	assert.False(t, w.Modified())
+	assert.Nil(t, db.oracle.writes)
+}
+
+func TestFastDB_WatchSameValue(t *testing.T) {
+	db := InitTestDb(t, KeyValueMemMode)
+	defer db.Close()
+
+	// setting the same value writes nothing, but it is still a modification.
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	w := db.Watch([]byte("k1"))
+	_ = db.Set([]byte("k1"), []byte("v1"))
+	assert.True(t, w.Modified())
+	w.Close()
+
+	_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+	w = db.Watch([]byte("h1"))
+	_, _ = db.HSet([]byte("h1"), []byte("f1"), []byte("v1"))
+	assert.True(t, w.Modified())
+	w.Close()
+}
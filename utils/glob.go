@@ -0,0 +1,87 @@
+package utils
+
+// GlobMatch reports whether s matches the glob-style pattern, the same as the pattern of the redis KEYS command:
+//
+//	?      matches any single character
+//	*      matches any sequence of characters
+//	[abc]  matches one character in the brackets, [^abc] matches one not in them, [a-z] matches a range
+//	\x     matches the character x literally
+//
+// Only the last star is backtracked when a match fails, so it takes O(len(pattern)*len(s)) time at most.
+func GlobMatch(pattern, s string) bool {
+	var p, i int
+	// the position after the last star in pattern, and the position in s where the star stops matching.
+	starP, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				starP, starI = p+1, i
+				p++
+				continue
+			}
+			if n, ok := matchOne(pattern[p:], s[i]); ok {
+				p, i = p+n, i+1
+				continue
+			}
+		}
+		// the last star matches one more character, and the rest of the pattern is matched again.
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP, starI
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// match c with the first element of the pattern, which is not a star, returns the length of the element.
+func matchOne(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '[':
+		rest, matched := matchClass(pattern[1:], c)
+		return len(pattern) - len(rest), matched
+	case '\\':
+		if len(pattern) > 1 {
+			return 2, pattern[1] == c
+		}
+	}
+	return 1, pattern[0] == c
+}
+
+// match c with the character class after '[', returns the pattern after ']'.
+// The class without ']' lasts to the end of the pattern.
+func matchClass(pattern string, c byte) (string, bool) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+
+	var matched bool
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return pattern, matched != not
+}
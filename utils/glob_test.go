@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		matched    bool
+	}{
+		{"*", "", true},
+		{"*", "any", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"*:1", "user:1", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"a/*", "a/b/c", true},
+		{"abc", "abcd", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"*a", "", false},
+		{"a**", "a", true},
+		{"[a-c]*\\?", "b-x?", true},
+		{"h[ae", "ha", true},
+		{"\\", "\\", true},
+	}
+	for _, tt := range tests {
+		if matched := GlobMatch(tt.pattern, tt.s); matched != tt.matched {
+			t.Errorf("GlobMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, matched, tt.matched)
+		}
+	}
+}
+
+func TestGlobMatch_Backtracking(t *testing.T) {
+	pattern := strings.Repeat("*a", 20) + "*b"
+	s := strings.Repeat("a", 10000)
+
+	start := time.Now()
+	if GlobMatch(pattern, s) {
+		t.Errorf("GlobMatch(%q, ...) = true, want false", pattern)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GlobMatch takes too long: %v", elapsed)
+	}
+}